@@ -0,0 +1,177 @@
+package client
+
+import (
+	"sync"
+	"time"
+
+	"github.com/globalpokecache/POGOProtos-go"
+	"github.com/globalpokecache/pogobuf-go/helpers"
+)
+
+// Activity is the kind of motion reported on the signatures
+type Activity int
+
+const (
+	ActivityUnknown Activity = iota
+	ActivityStationary
+	ActivityWalking
+	ActivityRunning
+	ActivityCycling
+	ActivityAutomotive
+)
+
+var activityNames = map[Activity]string{
+	ActivityUnknown:    "unknown",
+	ActivityStationary: "stationary",
+	ActivityWalking:    "walking",
+	ActivityRunning:    "running",
+	ActivityCycling:    "cycling",
+	ActivityAutomotive: "automotive",
+}
+
+func (a Activity) String() string {
+	if name, ok := activityNames[a]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+func (a Activity) status() *protos.Signature_ActivityStatus {
+	status := &protos.Signature_ActivityStatus{}
+	switch a {
+	case ActivityStationary:
+		status.Stationary = true
+	case ActivityWalking:
+		status.Walking = true
+	case ActivityRunning:
+		status.Running = true
+	case ActivityCycling:
+		status.Cycling = true
+	case ActivityAutomotive:
+		status.Automotive = true
+	default:
+		status.UnknownStatus = true
+	}
+	return status
+}
+
+const (
+	// Positions older than this are not used to classify the activity
+	activityWindow = 30 * time.Second
+	// History needed before the activity is known
+	activityMinSpan = 5 * time.Second
+	// Faster moves are jumps and restart the history
+	activityMaxSpeed = 60.0
+)
+
+// Upper average speed in m/s of each activity, faster ones are automotive
+var activitySpeeds = []struct {
+	speed    float64
+	activity Activity
+}{
+	{0.5, ActivityStationary},
+	{2.5, ActivityWalking},
+	{4.5, ActivityRunning},
+	{8, ActivityCycling},
+}
+
+// ActivityState is the activity reported on the signatures
+type ActivityState struct {
+	Activity Activity
+	// Since is when the classified activity started
+	Since time.Time
+	// Speed is the average speed in m/s over the recent positions
+	Speed float64
+	// Overridden is set when the activity was set by SetActivity
+	Overridden bool
+}
+
+type positionSample struct {
+	time     time.Time
+	position helpers.LatLng
+}
+
+type activityTracker struct {
+	sync.Mutex
+	samples  []positionSample
+	state    ActivityState
+	override *Activity
+}
+
+// observe adds a player position and classifies the activity again
+func (t *activityTracker) observe(now time.Time, pos helpers.LatLng) {
+	t.Lock()
+	defer t.Unlock()
+
+	if n := len(t.samples); n > 0 {
+		last := t.samples[n-1]
+		elapsed := now.Sub(last.time).Seconds()
+		if elapsed > 0 && helpers.Distance(last.position, pos)/elapsed > activityMaxSpeed {
+			t.samples = nil
+		}
+	}
+	t.samples = append(t.samples, positionSample{now, pos})
+
+	first := 0
+	for first < len(t.samples)-1 && now.Sub(t.samples[first].time) > activityWindow {
+		first++
+	}
+	t.samples = t.samples[first:]
+
+	activity := ActivityUnknown
+	span := now.Sub(t.samples[0].time)
+	t.state.Speed = 0
+	if span >= activityMinSpan {
+		var distance float64
+		for i := 1; i < len(t.samples); i++ {
+			distance += helpers.Distance(t.samples[i-1].position, t.samples[i].position)
+		}
+		t.state.Speed = distance / span.Seconds()
+
+		activity = ActivityAutomotive
+		for _, s := range activitySpeeds {
+			if t.state.Speed < s.speed {
+				activity = s.activity
+				break
+			}
+		}
+	}
+
+	if activity != t.state.Activity || t.state.Since.IsZero() {
+		t.state.Activity = activity
+		t.state.Since = now
+	}
+}
+
+func (t *activityTracker) get() ActivityState {
+	t.Lock()
+	defer t.Unlock()
+
+	state := t.state
+	if t.override != nil {
+		state.Activity = *t.override
+		state.Overridden = true
+	}
+	return state
+}
+
+// Activity returns the activity reported on the signatures, classified from
+// the player positions of the last 30 seconds unless set by SetActivity
+func (c *Instance) Activity() ActivityState {
+	return c.activity.get()
+}
+
+// SetActivity reports activity on the signatures instead of the classified one
+// until ClearActivity is called
+func (c *Instance) SetActivity(activity Activity) {
+	c.activity.Lock()
+	c.activity.override = &activity
+	c.activity.Unlock()
+}
+
+// ClearActivity goes back to reporting the classified activity
+func (c *Instance) ClearActivity() {
+	c.activity.Lock()
+	c.activity.override = nil
+	c.activity.Unlock()
+}
@@ -0,0 +1,55 @@
+package client
+
+import (
+	"testing"
+	"time"
+
+	"github.com/globalpokecache/pogobuf-go/helpers"
+)
+
+func TestActivityTracker(t *testing.T) {
+	var tracker activityTracker
+	now := time.Now()
+	pos := helpers.LatLng{Lat: 40, Lng: -74}
+
+	walk := func(seconds int, speed float64) {
+		for i := 0; i < seconds; i++ {
+			now = now.Add(time.Second)
+			pos = helpers.Destination(pos, 90, speed)
+			tracker.observe(now, pos)
+		}
+	}
+
+	walk(1, 0)
+	if a := tracker.get().Activity; a != ActivityUnknown {
+		t.Fatalf("Expected unknown without history, got %s", a)
+	}
+
+	walk(10, 0)
+	if a := tracker.get().Activity; a != ActivityStationary {
+		t.Fatalf("Expected stationary, got %s", a)
+	}
+
+	walk(40, 1.4)
+	if a := tracker.get().Activity; a != ActivityWalking {
+		t.Fatalf("Expected walking, got %s", a)
+	}
+
+	walk(40, 15)
+	if a := tracker.get().Activity; a != ActivityAutomotive {
+		t.Fatalf("Expected automotive, got %s", a)
+	}
+
+	// A teleport restarts the history instead of looking like a fast drive
+	pos = helpers.Destination(pos, 0, 50000)
+	walk(1, 0)
+	if a := tracker.get().Activity; a != ActivityUnknown {
+		t.Fatalf("Expected unknown after a jump, got %s", a)
+	}
+
+	override := ActivityCycling
+	tracker.override = &override
+	if state := tracker.get(); state.Activity != ActivityCycling || !state.Overridden {
+		t.Fatalf("Override not applied %+v", state)
+	}
+}
@@ -2,17 +2,22 @@ package client
 
 import (
 	"context"
-	"crypto/rand"
+	crand "crypto/rand"
 	"errors"
 	"fmt"
+	"math/rand"
 	"sync"
 	"time"
 
 	"github.com/globalpokecache/POGOProtos-go"
 	"github.com/globalpokecache/pogobuf-go"
 	"github.com/globalpokecache/pogobuf-go/auth"
+	"github.com/globalpokecache/pogobuf-go/clock"
+	"github.com/globalpokecache/pogobuf-go/elevation"
 	"github.com/globalpokecache/pogobuf-go/hash"
 	"github.com/globalpokecache/pogobuf-go/helpers"
+	"github.com/globalpokecache/pogobuf-go/pcrypt"
+	"github.com/globalpokecache/pogobuf-go/versions"
 	"github.com/golang/protobuf/proto"
 )
 
@@ -24,16 +29,51 @@ type Options struct {
 	SimulateApp          bool
 	AutoCompleteTutorial bool
 	GoogleMapsKey        string
+	// ElevationProvider sets the player altitude on SetPosition and while
+	// moving, a cached Google Maps provider is used when only GoogleMapsKey
+	// is set
+	ElevationProvider elevation.Provider
+	// SensorModel produces the signature sensor readings, HandheldSensors by
+	// default
+	SensorModel SensorModel
+	// Rand is the source of every random value of the instance, from the
+	// location fixes to the delays. It must not be shared with other
+	// instances, a source seeded with a fixed value reproduces a session.
+	Rand rand.Source
+	// SeededSessionHash draws the session hash from Rand too, by default it
+	// comes from crypto/rand even with a seeded Rand
+	SeededSessionHash bool
+	// Clock is the source of time of the timestamps and delays, the system
+	// clock by default
+	Clock clock.Clock
+	// Timing sets the delays of the simulated app and tutorial, see
+	// TimingRealistic, TimingFast, TimingCautious and LoadTimingProfile
+	Timing TimingProfile
+	// DeviceCatalog is where the account device is picked from when
+	// SignatureInfo has none, IOSCatalog or AndroidCatalog by default
+	DeviceCatalog *DeviceCatalog
+	// Platform is the app emulated, protos.Platform_IOS by default or
+	// protos.Platform_ANDROID. It sets the platform of the requests, the
+	// device info, the location fix providers and the asset digest fields.
+	// The login sequence stays the iOS one, Android only skips
+	// REGISTER_BACKGROUND_DEVICE.
+	Platform protos.Platform
+	// Locale is the region of the app, DefaultLocale by default
+	Locale Locale
 
 	MaxTries             int
 	MapObjectsMinDelay   time.Duration
 	MinRequestInterval   time.Duration
 	MapObjectsThrottling bool
+
+	// CooldownMode sets whether FortSearch, Encounter and CatchPokemon wait
+	// for or fail on the cooldown after a jump, by default it is only tracked
+	CooldownMode CooldownMode
 }
 
 var (
 	defaultOptions = Options{
-		Version:              5500,
+		Version:              versions.Default,
 		SignatureInfo:        SignatureInfo{},
 		HashProvider:         nil,
 		MaxTries:             3,
@@ -48,8 +88,17 @@ var (
 	DefaultLehmerSeed int64 = 16807
 )
 
+// Elevations kept by the default Google Maps provider
+const defaultElevationCacheSize = 1000
+
 type Instance struct {
 	options            Options
+	version            versions.Profile
+	cipher             pcrypt.Cipher
+	rand               *random
+	fixerRand          *random
+	moveRand           *random
+	clock              clock.Clock
 	player             Player
 	rpc                *RPC
 	lehmerSeed         int64
@@ -65,7 +114,18 @@ type Instance struct {
 	serverURL          string
 	firstGetMap        bool
 	mapSettings        protos.MapSettings
-	waitRequest        chan struct{}
+	mapState           *MapState
+	mapCells           []uint64
+	fixerPosition      helpers.LatLng
+	requestMutex       sync.Mutex
+	nextRequest        time.Time
+
+	lastAction actionTracker
+	activity   activityTracker
+
+	movementMutex  sync.Mutex
+	movementCancel func()
+	movementID     int64
 
 	locationFixSync    sync.Mutex
 	lastLocationCourse float32
@@ -75,7 +135,12 @@ type Instance struct {
 	cancel func()
 }
 
+// New creates an instance from a copy of opts, the defaults are filled in the
+// copy so opts can be reused for other accounts
 func New(opts *Options) (*Instance, error) {
+	copied := *opts
+	opts = &copied
+
 	if opts.HashProvider == nil {
 		return nil, errors.New("Missing Hash Provider")
 	}
@@ -84,28 +149,96 @@ func New(opts *Options) (*Instance, error) {
 		return nil, errors.New("Missing Auth Provider")
 	}
 
-	if opts.Version != 0 {
-		if _, err := getUnk25(opts.Version); err != nil {
-			return nil, err
-		}
-	} else {
+	if opts.Version == 0 {
 		opts.Version = defaultOptions.Version
 	}
+	version, err := versions.Get(opts.Version)
+	if err != nil {
+		return nil, err
+	}
+	cipher, err := pcrypt.ForVersion(version.Version, version.Encryption.Key, version.Encryption.IntegrityByte)
+	if err != nil {
+		return nil, err
+	}
+
+	switch opts.Platform {
+	case protos.Platform_UNSET:
+		opts.Platform = protos.Platform_IOS
+	case protos.Platform_IOS, protos.Platform_ANDROID:
+	default:
+		return nil, ErrPlatformNotSupported
+	}
+	if !version.Supports(devicePlatform(opts.Platform)) {
+		return nil, fmt.Errorf("Version %s does not support %s", version.Name(), devicePlatform(opts.Platform))
+	}
 
 	if opts.MaxTries == 0 {
 		opts.MaxTries = defaultOptions.MaxTries
 	}
 
-	if opts.SignatureInfo.DeviceInfo == nil {
-		opts.SignatureInfo.DeviceInfo = NewDevice(opts.AuthProvider)
+	if opts.ElevationProvider == nil && opts.GoogleMapsKey != "" {
+		google, err := elevation.NewGoogle(opts.GoogleMapsKey)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to create elevation provider: %s", err)
+		}
+		opts.ElevationProvider = elevation.NewCached(google, defaultElevationCacheSize)
+	}
+
+	// Every consumer has its own stream split in a fixed order
+	random := newRandom(opts.Rand)
+	fixerRand := random.split()
+	moveRand := random.split()
+	sensorRand := random.split()
+
+	if opts.Timing.Delays == nil {
+		opts.Timing = TimingRealistic
+	}
+
+	if opts.Clock == nil {
+		opts.Clock = clock.Real{}
+	}
+
+	if opts.SensorModel == nil {
+		opts.SensorModel = &HandheldSensors{rand: sensorRand}
+	}
+
+	if opts.Locale == (Locale{}) {
+		opts.Locale = DefaultLocale
+	}
+
+	if device := opts.SignatureInfo.DeviceInfo; device != nil {
+		if p := deviceInfoPlatform(device); p != "" && p != devicePlatform(opts.Platform) {
+			return nil, fmt.Errorf("Device info is for %s, not %s", p, devicePlatform(opts.Platform))
+		}
+	} else {
+		if opts.DeviceCatalog == nil {
+			opts.DeviceCatalog = IOSCatalog
+			if opts.Platform == protos.Platform_ANDROID {
+				opts.DeviceCatalog = AndroidCatalog
+			}
+		}
+		if opts.DeviceCatalog.Platform != devicePlatform(opts.Platform) {
+			return nil, fmt.Errorf("Device catalog is for %s, not %s", opts.DeviceCatalog.Platform, devicePlatform(opts.Platform))
+		}
+		device, err := opts.DeviceCatalog.Device(opts.AuthProvider.GetUsername())
+		if err != nil {
+			return nil, err
+		}
+		opts.SignatureInfo.DeviceInfo = device
 	}
 
 	return &Instance{
-		options:     *opts,
-		rpc:         NewRPC(),
-		lehmerSeed:  DefaultLehmerSeed,
-		ptr8:        DefaultPtr8,
-		waitRequest: make(chan struct{}),
+		options:    *opts,
+		version:    version,
+		cipher:     cipher,
+		rand:       random,
+		fixerRand:  fixerRand,
+		moveRand:   moveRand,
+		clock:      opts.Clock,
+		rpc:        NewRPC(),
+		lehmerSeed: DefaultLehmerSeed,
+		ptr8:       DefaultPtr8,
+		mapState:   NewMapState(),
 	}, nil
 }
 
@@ -114,7 +247,7 @@ func (c *Instance) BuildCommon(init bool) []*protos.Request {
 	getHatchedEggs, _ := c.GetHatchedEggsRequest()
 	getInventory, _ := c.GetInventoryRequest(c.inventoryTimestamp)
 	checkAwarded, _ := c.CheckAwardedBadgesRequest()
-	downloadSettings, _ := c.DownloadSettingsRequest(downloadSettingsHash)
+	downloadSettings, _ := c.DownloadSettingsRequest(c.version.SettingsHash)
 
 	reqs := []*protos.Request{
 		checkChallenge,
@@ -133,9 +266,9 @@ func (c *Instance) BuildCommon(init bool) []*protos.Request {
 func (c *Instance) simulateAppLogin(ctx context.Context) (*protos.GetPlayerResponse, *protos.ResponseEnvelope, error) {
 	c.Call(ctx)
 
-	randSleep(430, 970)
+	c.pause(PauseAppStart)
 
-	getPlayerReq, _ := c.GetPlayerRequest("US", "en", "America/Chicago")
+	getPlayerReq, _ := c.playerRequest()
 	response, err := c.Call(ctx, getPlayerReq)
 	if err != nil {
 		return nil, nil, err
@@ -155,9 +288,9 @@ func (c *Instance) simulateAppLogin(ctx context.Context) (*protos.GetPlayerRespo
 		return nil, nil, pogobuf.ErrAccountBanned
 	}
 
-	randSleep(530, 1000)
+	c.pause(PauseAfterGetPlayer)
 
-	downloadRemoteConfigReq, _ := c.DownloadRemoteConfigVersionRequest(protos.Platform_IOS, c.options.Version)
+	downloadRemoteConfigReq, _ := c.DownloadRemoteConfigVersionRequest(c.options.Platform, c.options.Version)
 	downloadSettings, _ := c.DownloadSettingsRequest("")
 	var requests = []*protos.Request{downloadRemoteConfigReq}
 	requests = append(requests, c.BuildCommon(true)...)
@@ -213,7 +346,7 @@ func (c *Instance) simulateAppLogin(ctx context.Context) (*protos.GetPlayerRespo
 		c.mapSettings = *mapSettings
 	}
 
-	getAssetDigest, _ := c.GetAssetDigestRequest(protos.Platform_IOS, "", "", "", c.options.Version)
+	getAssetDigest, _ := c.assetDigestRequest()
 	requests = []*protos.Request{getAssetDigest}
 	requests = append(requests, c.BuildCommon(true)...)
 	assetResp, err := c.Call(ctx, requests...)
@@ -225,7 +358,7 @@ func (c *Instance) simulateAppLogin(ctx context.Context) (*protos.GetPlayerRespo
 		return nil, nil, errors.New("Failed to initialize real player client")
 	}
 
-	randSleep(870, 2000)
+	c.pause(PauseAfterAssetDigest)
 
 	getBuddyWalkedReq, _ := c.GetBuddyWalkedRequest()
 
@@ -263,7 +396,7 @@ func (c *Instance) simulateAppLogin(ctx context.Context) (*protos.GetPlayerRespo
 		if err != nil {
 			return nil, nil, err
 		}
-		randSleep(200, 400)
+		c.pause(PauseAfterPlayerProfile)
 
 		if level != nil {
 			levelUpReq, _ := c.LevelUpRewardsRequest(*level)
@@ -274,25 +407,29 @@ func (c *Instance) simulateAppLogin(ctx context.Context) (*protos.GetPlayerRespo
 			if err != nil {
 				return nil, nil, err
 			}
-			randSleep(450, 700)
+			c.pause(PauseAfterLevelUpRewards)
 		}
 
-		regBg, _ := c.RegisterBackgroundDeviceRequest("", "apple_watch")
-		requests = append([]*protos.Request{}, regBg)
-		requests = append(requests, c.BuildCommon(true)...)
-		requests = append(requests, getBuddyWalkedReq)
-		_, err = c.Call(ctx, requests...)
-		if err != nil {
-			return nil, nil, err
+		// The sequence is the same on Android except that only the iOS app
+		// looks for a paired Apple Watch
+		if c.options.Platform == protos.Platform_IOS {
+			regBg, _ := c.RegisterBackgroundDeviceRequest("", "apple_watch")
+			requests = append([]*protos.Request{}, regBg)
+			requests = append(requests, c.BuildCommon(true)...)
+			requests = append(requests, getBuddyWalkedReq)
+			_, err = c.Call(ctx, requests...)
+			if err != nil {
+				return nil, nil, err
+			}
+			c.pause(PauseAfterRegisterBackground)
 		}
-		randSleep(500, 1300)
 	}
 
 	return &getPlayer, response, nil
 }
 
 func (c *Instance) minimalLogin(ctx context.Context) (*protos.GetPlayerResponse, *protos.ResponseEnvelope, error) {
-	getPlayerReq, _ := c.GetPlayerRequest("US", "en", "America/Chicago")
+	getPlayerReq, _ := c.playerRequest()
 	response, err := c.Call(ctx, getPlayerReq)
 	if err != nil {
 		return nil, nil, err
@@ -317,7 +454,11 @@ func (c *Instance) minimalLogin(ctx context.Context) (*protos.GetPlayerResponse,
 
 func (c *Instance) newSessionHash() error {
 	shash := make([]byte, 16)
-	_, err := rand.Read(shash)
+	read := crand.Read
+	if c.options.SeededSessionHash {
+		read = c.rand.Read
+	}
+	_, err := read(shash)
 	if err != nil {
 		return err
 	}
@@ -356,13 +497,15 @@ func (c *Instance) Init(ctx context.Context) (*protos.GetPlayerResponse, *protos
 	c.lehmerSeed = DefaultLehmerSeed
 	c.inventoryTimestamp = 0
 	c.firstGetMap = true
+	c.mapState.Reset()
+	c.mapCells = nil
 	c.locationFixes = make(chan *protos.Signature_LocationFix, 20)
-	c.startedTime = getTimestamp(time.Now()) - uint64(5000+randInt(800))
+	c.startedTime = getTimestamp(c.clock.Now()) - uint64(5000+c.rand.Intn(800))
 
-	go c.locationFixer(ctx)
-	go c.requestThrottle(ctx)
+	c.fixerPosition = helpers.LatLng{Lat: c.player.Latitude(), Lng: c.player.Longitude()}
+	c.locationFixer(ctx)
 
-	randSleep(500, 800)
+	c.pause(PauseAfterInit)
 
 	if c.options.SimulateApp {
 		return c.simulateAppLogin(ctx)
@@ -371,11 +514,47 @@ func (c *Instance) Init(ctx context.Context) (*protos.GetPlayerResponse, *protos
 	return c.minimalLogin(ctx)
 }
 
+// MapOptions sets the area requested by GetMapWithOptions
+type MapOptions struct {
+	// Radius around the player in meters, defaults to 640
+	Radius float64
+	// Level of the requested S2 cells, defaults to 15
+	Level int
+}
+
+const defaultMapRadius = 640
+
 func (c *Instance) GetMap(ctx context.Context) (*protos.GetMapObjectsResponse, *protos.ResponseEnvelope, error) {
-	cells := helpers.GetCellsFromRadius(c.player.Latitude(), c.player.Longitude(), 640, 15)
+	return c.GetMapWithOptions(ctx, MapOptions{})
+}
+
+// GetMapWithOptions works like GetMap with a custom scan radius and cell level.
+// It fails with helpers.ErrTooManyCells when the area does not fit in a single
+// request instead of dropping cells.
+//
+// Cells are requested with the timestamps of the previous scans, the response
+// is the one of the server and only holds what changed since. The merged
+// content of the cells is returned by MapCells.
+func (c *Instance) GetMapWithOptions(ctx context.Context, opts MapOptions) (*protos.GetMapObjectsResponse, *protos.ResponseEnvelope, error) {
 	var response *protos.ResponseEnvelope
 
-	getMapReq, err := c.GetMapObjectsRequest(cells, make([]int64, len(cells)))
+	if opts.Radius <= 0 {
+		opts.Radius = defaultMapRadius
+	}
+	if opts.Level == 0 {
+		opts.Level = helpers.MapCellLevel
+	}
+
+	region := helpers.CapRegion(c.player.Latitude(), c.player.Longitude(), opts.Radius)
+	cells, err := helpers.Cover(region, helpers.CoverOptions{MinLevel: opts.Level, MaxLevel: opts.Level})
+	if err != nil {
+		return nil, response, err
+	}
+
+	c.expireMapState()
+	since := c.mapState.SinceTimestamps(cells)
+
+	getMapReq, err := c.GetMapObjectsRequest(cells, since)
 	if err != nil {
 		return nil, response, err
 	}
@@ -422,6 +601,8 @@ func (c *Instance) GetMap(ctx context.Context) (*protos.GetMapObjectsResponse, *
 		debugProto("MapObjects", &getMapObjects)
 	}
 
+	c.mergeMapObjects(cells, since, &getMapObjects)
+
 	return &getMapObjects, response, nil
 }
 
@@ -429,6 +610,35 @@ func (c Instance) MapSettings() protos.MapSettings {
 	return c.mapSettings
 }
 
+// MapState returns the map cells merged from the previous scans
+func (c *Instance) MapState() *MapState {
+	return c.mapState
+}
+
+// MapCells returns the merged content of the cells of the last map request
+func (c *Instance) MapCells() []*protos.MapCell {
+	return c.mapState.Cells(c.mapCells)
+}
+
+// mapCellMaxAge is how long the cells not requested again are remembered
+const mapCellMaxAge = 15 * time.Minute
+
+func (c *Instance) expireMapState() {
+	c.mapState.Expire(int64(getTimestamp(c.clock.Now().Add(-mapCellMaxAge))))
+}
+
+func (c *Instance) mergeMapObjects(cellIDs []uint64, sinceTimestampMs []int64, resp *protos.GetMapObjectsResponse) {
+	var full []uint64
+	for i, id := range cellIDs {
+		if i >= len(sinceTimestampMs) || sinceTimestampMs[i] == 0 {
+			full = append(full, id)
+		}
+	}
+	c.mapState.Forget(full)
+	c.mapState.Merge(resp)
+	c.mapCells = cellIDs
+}
+
 func (c *Instance) SetAuthToken(authToken string) {
 	c.authToken = authToken
 	c.authTicket = nil
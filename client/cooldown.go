@@ -0,0 +1,151 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/globalpokecache/pogobuf-go/helpers"
+)
+
+// CooldownMode sets how gated actions behave while a cooldown is active
+type CooldownMode int
+
+const (
+	// CooldownIgnore only tracks the cooldown, actions run right away
+	CooldownIgnore CooldownMode = iota
+	// CooldownWait blocks gated actions until the cooldown is over
+	CooldownWait
+	// CooldownError makes gated actions fail with ErrCooldownActive
+	CooldownError
+)
+
+// ErrCooldownActive is returned by gated actions while the player has to wait
+// after a jump
+type ErrCooldownActive struct {
+	Remaining time.Duration
+}
+
+func (e ErrCooldownActive) Error() string {
+	return fmt.Sprintf("Cooldown active, %s remaining", e.Remaining)
+}
+
+// Community known wait after an action before the next one, by distance
+// traveled between both
+var cooldownTable = []struct {
+	distance float64 // km
+	wait     time.Duration
+}{
+	{1, 1 * time.Minute},
+	{2, 1 * time.Minute},
+	{4, 2 * time.Minute},
+	{5, 2 * time.Minute},
+	{6, 4 * time.Minute},
+	{7, 5 * time.Minute},
+	{8, 6 * time.Minute},
+	{10, 7 * time.Minute},
+	{12, 8 * time.Minute},
+	{18, 10 * time.Minute},
+	{26, 15 * time.Minute},
+	{42, 19 * time.Minute},
+	{65, 22 * time.Minute},
+	{81, 25 * time.Minute},
+	{100, 35 * time.Minute},
+	{220, 40 * time.Minute},
+	{250, 45 * time.Minute},
+	{350, 51 * time.Minute},
+	{375, 54 * time.Minute},
+	{460, 62 * time.Minute},
+	{500, 65 * time.Minute},
+	{565, 69 * time.Minute},
+	{700, 78 * time.Minute},
+	{750, 82 * time.Minute},
+	{825, 88 * time.Minute},
+	{985, 100 * time.Minute},
+	{1100, 110 * time.Minute},
+	{1335, 120 * time.Minute},
+}
+
+// CooldownFor returns the wait required after moving distance meters since
+// the last action. Jumps under a kilometer need no wait.
+func CooldownFor(distance float64) time.Duration {
+	km := distance / 1000
+	if km < 1 {
+		return 0
+	}
+	for _, step := range cooldownTable {
+		if km <= step.distance {
+			return step.wait
+		}
+	}
+	return cooldownTable[len(cooldownTable)-1].wait
+}
+
+type actionTracker struct {
+	sync.Mutex
+	done     bool
+	position helpers.LatLng
+	time     time.Time
+}
+
+// Cooldown returns how long the player still has to wait before a gated
+// action (FortSearch, Encounter, CatchPokemon) at its current position
+func (c *Instance) Cooldown() time.Duration {
+	return c.cooldownAt(helpers.LatLng{Lat: c.player.Latitude(), Lng: c.player.Longitude()})
+}
+
+// CooldownAt returns how long the player would have to wait before a gated
+// action at lat, lng, for schedulers planning the next jump
+func (c *Instance) CooldownAt(lat, lng float64) time.Duration {
+	return c.cooldownAt(helpers.LatLng{Lat: lat, Lng: lng})
+}
+
+func (c *Instance) cooldownAt(pos helpers.LatLng) time.Duration {
+	c.lastAction.Lock()
+	defer c.lastAction.Unlock()
+
+	if !c.lastAction.done {
+		return 0
+	}
+
+	wait := CooldownFor(helpers.Distance(c.lastAction.position, pos))
+	remaining := c.lastAction.time.Add(wait).Sub(c.clock.Now())
+	if remaining < 0 {
+		return 0
+	}
+	return remaining
+}
+
+// waitCooldown applies the cooldown mode before a gated action
+func (c *Instance) waitCooldown(ctx context.Context) error {
+	remaining := c.Cooldown()
+	if remaining <= 0 {
+		return nil
+	}
+
+	switch c.options.CooldownMode {
+	case CooldownWait:
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-c.clock.After(remaining):
+		}
+		// The player may have moved while waiting
+		return c.waitCooldown(ctx)
+	case CooldownError:
+		return ErrCooldownActive{remaining}
+	}
+
+	return nil
+}
+
+// actionDone records a gated action at the current player position, only
+// call it once the server accepted the action
+func (c *Instance) actionDone() {
+	c.lastAction.Lock()
+	c.lastAction.done = true
+	c.lastAction.position = helpers.LatLng{Lat: c.player.Latitude(), Lng: c.player.Longitude()}
+	c.lastAction.time = c.clock.Now()
+	c.lastAction.Unlock()
+}
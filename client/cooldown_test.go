@@ -0,0 +1,50 @@
+package client
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/globalpokecache/pogobuf-go/clock"
+)
+
+func TestCooldownFor(t *testing.T) {
+	cases := []struct {
+		distance float64
+		wait     time.Duration
+	}{
+		{500, 0},
+		{1500, time.Minute},
+		{9000, 7 * time.Minute},
+		{100000, 35 * time.Minute},
+		{5000000, 120 * time.Minute},
+	}
+	for _, c := range cases {
+		if wait := CooldownFor(c.distance); wait != c.wait {
+			t.Fatalf("CooldownFor(%v) = %s, expected %s", c.distance, wait, c.wait)
+		}
+	}
+}
+
+func TestWaitCooldown(t *testing.T) {
+	fake := clock.NewFake(time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC))
+	fake.SetAutoAdvance(true)
+	c := &Instance{clock: fake, options: Options{CooldownMode: CooldownWait}}
+
+	c.player.SetLatitude(40)
+	c.player.SetLongitude(-74)
+	c.actionDone()
+
+	c.player.SetLatitude(40.1)
+	if remaining := c.Cooldown(); remaining != 8*time.Minute {
+		t.Fatalf("Expected 8m cooldown, got %s", remaining)
+	}
+
+	start := fake.Now()
+	if err := c.waitCooldown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if waited := fake.Now().Sub(start); waited != 8*time.Minute {
+		t.Fatalf("Expected to wait 8m, waited %s", waited)
+	}
+}
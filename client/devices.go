@@ -0,0 +1,289 @@
+package client
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"strconv"
+	"strings"
+
+	"github.com/globalpokecache/POGOProtos-go"
+	"github.com/globalpokecache/pogobuf-go/auth"
+	"github.com/satori/go.uuid"
+)
+
+const (
+	DevicePlatformIOS     = "ios"
+	DevicePlatformAndroid = "android"
+)
+
+var ErrEmptyCatalog = errors.New("Device catalog has no usable device")
+
+// DeviceFirmware is an OS build a device model can run, with the fields of
+// Signature_DeviceInfo that change with it
+type DeviceFirmware struct {
+	// FirmwareType is the iOS version, or the build type on Android
+	FirmwareType          string `json:"firmware_type"`
+	FirmwareFingerprint   string `json:"firmware_fingerprint,omitempty"`
+	DeviceModelIdentifier string `json:"device_model_identifier,omitempty"`
+	AndroidBootloader     string `json:"android_bootloader,omitempty"`
+}
+
+// DeviceModel is a device of a catalog. On Android the fields hold the Build
+// properties: DeviceModel is the device, DeviceModelBoot the boot hardware,
+// HardwareModel the model and FirmwareBrand the product name.
+type DeviceModel struct {
+	AndroidBoardName     string           `json:"android_board_name,omitempty"`
+	DeviceBrand          string           `json:"device_brand"`
+	DeviceModel          string           `json:"device_model"`
+	DeviceModelBoot      string           `json:"device_model_boot"`
+	HardwareManufacturer string           `json:"hardware_manufacturer"`
+	HardwareModel        string           `json:"hardware_model"`
+	FirmwareBrand        string           `json:"firmware_brand"`
+	FirmwareTags         string           `json:"firmware_tags,omitempty"`
+	Firmwares            []DeviceFirmware `json:"firmwares"`
+}
+
+// DeviceCatalog is the list of devices an account may report, it is read
+// from JSON files with LoadDeviceCatalog
+type DeviceCatalog struct {
+	Platform string        `json:"platform"`
+	Devices  []DeviceModel `json:"devices"`
+}
+
+// LoadDeviceCatalog reads a catalog from a JSON file
+func LoadDeviceCatalog(path string) (*DeviceCatalog, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var catalog DeviceCatalog
+	if err := json.Unmarshal(data, &catalog); err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	if err := catalog.validate(); err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	return &catalog, nil
+}
+
+func (c *DeviceCatalog) validate() error {
+	switch c.Platform {
+	case DevicePlatformIOS, DevicePlatformAndroid:
+	default:
+		return fmt.Errorf("Unknown device platform %q", c.Platform)
+	}
+	for _, d := range c.Devices {
+		if len(d.Firmwares) > 0 {
+			return nil
+		}
+	}
+	return ErrEmptyCatalog
+}
+
+// Device returns the device of an account. The choice is seeded with the
+// username so an account always reports the same device and firmware.
+func (c *DeviceCatalog) Device(username string) (*protos.Signature_DeviceInfo, error) {
+	var devices []DeviceModel
+	for _, d := range c.Devices {
+		if len(d.Firmwares) > 0 {
+			devices = append(devices, d)
+		}
+	}
+	if len(devices) == 0 {
+		return nil, ErrEmptyCatalog
+	}
+
+	id := uuid.NewV5(uuid.Nil, username)
+	r := rand.New(rand.NewSource(int64(binary.BigEndian.Uint64(id.Bytes()))))
+
+	device := devices[r.Intn(len(devices))]
+	firmware := device.Firmwares[r.Intn(len(device.Firmwares))]
+
+	deviceID := strings.Replace(id.String(), "-", "", -1)
+	if c.Platform == DevicePlatformAndroid {
+		// Android ids are 64 bit
+		deviceID = deviceID[:16]
+	}
+
+	return &protos.Signature_DeviceInfo{
+		DeviceId:              deviceID,
+		AndroidBoardName:      device.AndroidBoardName,
+		AndroidBootloader:     firmware.AndroidBootloader,
+		DeviceBrand:           device.DeviceBrand,
+		DeviceModel:           device.DeviceModel,
+		DeviceModelIdentifier: firmware.DeviceModelIdentifier,
+		DeviceModelBoot:       device.DeviceModelBoot,
+		HardwareManufacturer:  device.HardwareManufacturer,
+		HardwareModel:         device.HardwareModel,
+		FirmwareBrand:         device.FirmwareBrand,
+		FirmwareTags:          device.FirmwareTags,
+		FirmwareType:          firmware.FirmwareType,
+		FirmwareFingerprint:   firmware.FirmwareFingerprint,
+	}, nil
+}
+
+// NewDevice returns the iOS device of the account
+func NewDevice(p auth.Provider) *protos.Signature_DeviceInfo {
+	device, _ := IOSCatalog.Device(p.GetUsername())
+	return device
+}
+
+// First iOS major version of the models newer than iOS 8
+var iosMinVersion = map[string]int{
+	"iPhone8": 9,
+	"iPhone9": 10,
+}
+
+// IOSCatalog holds the Devices with the OsVersions each of them can run
+var IOSCatalog = newIOSCatalog()
+
+func newIOSCatalog() *DeviceCatalog {
+	catalog := &DeviceCatalog{Platform: DevicePlatformIOS}
+	for _, d := range Devices {
+		model := DeviceModel{
+			DeviceBrand:          "Apple",
+			DeviceModel:          d[1],
+			DeviceModelBoot:      d[0],
+			HardwareManufacturer: "Apple",
+			HardwareModel:        d[2],
+			FirmwareBrand:        "iPhone OS",
+		}
+
+		min := iosMinVersion[strings.SplitN(d[0], ",", 2)[0]]
+		for _, v := range OsVersions {
+			major, _ := strconv.Atoi(strings.SplitN(v, ".", 2)[0])
+			if major >= min {
+				model.Firmwares = append(model.Firmwares, DeviceFirmware{FirmwareType: v})
+			}
+		}
+
+		catalog.Devices = append(catalog.Devices, model)
+	}
+	return catalog
+}
+
+// AndroidCatalog holds common Android phones with their stock firmwares
+var AndroidCatalog = &DeviceCatalog{
+	Platform: DevicePlatformAndroid,
+	Devices: []DeviceModel{
+		{
+			AndroidBoardName:     "universal8890",
+			DeviceBrand:          "samsung",
+			DeviceModel:          "herolte",
+			DeviceModelBoot:      "samsungexynos8890",
+			HardwareManufacturer: "samsung",
+			HardwareModel:        "SM-G930F",
+			FirmwareBrand:        "heroltexx",
+			FirmwareTags:         "release-keys",
+			Firmwares: []DeviceFirmware{
+				{
+					FirmwareType:          "user",
+					FirmwareFingerprint:   "samsung/heroltexx/herolte:6.0.1/MMB29K/G930FXXU1APB4:user/release-keys",
+					DeviceModelIdentifier: "MMB29K.G930FXXU1APB4",
+					AndroidBootloader:     "G930FXXU1APB4",
+				},
+				{
+					FirmwareType:          "user",
+					FirmwareFingerprint:   "samsung/heroltexx/herolte:7.0/NRD90M/G930FXXU1DQAS:user/release-keys",
+					DeviceModelIdentifier: "NRD90M.G930FXXU1DQAS",
+					AndroidBootloader:     "G930FXXU1DQAS",
+				},
+			},
+		},
+		{
+			AndroidBoardName:     "universal7420",
+			DeviceBrand:          "samsung",
+			DeviceModel:          "zeroflte",
+			DeviceModelBoot:      "samsungexynos7420",
+			HardwareManufacturer: "samsung",
+			HardwareModel:        "SM-G920F",
+			FirmwareBrand:        "zerofltexx",
+			FirmwareTags:         "release-keys",
+			Firmwares: []DeviceFirmware{
+				{
+					FirmwareType:          "user",
+					FirmwareFingerprint:   "samsung/zerofltexx/zeroflte:6.0.1/MMB29K/G920FXXU5DPL4:user/release-keys",
+					DeviceModelIdentifier: "MMB29K.G920FXXU5DPL4",
+					AndroidBootloader:     "G920FXXU5DPL4",
+				},
+			},
+		},
+		{
+			AndroidBoardName:     "sailfish",
+			DeviceBrand:          "google",
+			DeviceModel:          "sailfish",
+			DeviceModelBoot:      "sailfish",
+			HardwareManufacturer: "Google",
+			HardwareModel:        "Pixel",
+			FirmwareBrand:        "sailfish",
+			FirmwareTags:         "release-keys",
+			Firmwares: []DeviceFirmware{
+				{
+					FirmwareType:          "user",
+					FirmwareFingerprint:   "google/sailfish/sailfish:7.1.1/NOF26V/3761073:user/release-keys",
+					DeviceModelIdentifier: "NOF26V",
+					AndroidBootloader:     "8996-012001-1611091517",
+				},
+			},
+		},
+		{
+			AndroidBoardName:     "bullhead",
+			DeviceBrand:          "google",
+			DeviceModel:          "bullhead",
+			DeviceModelBoot:      "bullhead",
+			HardwareManufacturer: "LGE",
+			HardwareModel:        "Nexus 5X",
+			FirmwareBrand:        "bullhead",
+			FirmwareTags:         "release-keys",
+			Firmwares: []DeviceFirmware{
+				{
+					FirmwareType:          "user",
+					FirmwareFingerprint:   "google/bullhead/bullhead:7.1.1/N4F26O/3582057:user/release-keys",
+					DeviceModelIdentifier: "N4F26O",
+					AndroidBootloader:     "BHZ11h",
+				},
+			},
+		},
+		{
+			AndroidBoardName:     "angler",
+			DeviceBrand:          "google",
+			DeviceModel:          "angler",
+			DeviceModelBoot:      "angler",
+			HardwareManufacturer: "Huawei",
+			HardwareModel:        "Nexus 6P",
+			FirmwareBrand:        "angler",
+			FirmwareTags:         "release-keys",
+			Firmwares: []DeviceFirmware{
+				{
+					FirmwareType:          "user",
+					FirmwareFingerprint:   "google/angler/angler:7.1.1/N4F26O/3582057:user/release-keys",
+					DeviceModelIdentifier: "N4F26O",
+					AndroidBootloader:     "angler-03.67",
+				},
+			},
+		},
+		{
+			AndroidBoardName:     "msm8996",
+			DeviceBrand:          "OnePlus",
+			DeviceModel:          "OnePlus3T",
+			DeviceModelBoot:      "qcom",
+			HardwareManufacturer: "OnePlus",
+			HardwareModel:        "ONEPLUS A3010",
+			FirmwareBrand:        "OnePlus3",
+			FirmwareTags:         "release-keys",
+			Firmwares: []DeviceFirmware{
+				{
+					FirmwareType:          "user",
+					FirmwareFingerprint:   "OnePlus/OnePlus3/OnePlus3T:7.0/NRD90M/12140141:user/release-keys",
+					DeviceModelIdentifier: "ONEPLUS A3010_28_170114",
+					AndroidBootloader:     "unknown",
+				},
+			},
+		},
+	},
+}
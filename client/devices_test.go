@@ -0,0 +1,52 @@
+package client
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestDeviceCatalog(t *testing.T) {
+	a, err := AndroidCatalog.Device("trainer1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := AndroidCatalog.Device("trainer1")
+	if a.String() != b.String() {
+		t.Fatal("An account must always get the same device")
+	}
+	if len(a.DeviceId) != 16 || a.FirmwareFingerprint == "" || a.FirmwareType != "user" {
+		t.Fatalf("Unexpected android device %v", a)
+	}
+
+	for _, d := range IOSCatalog.Devices {
+		if strings.HasPrefix(d.DeviceModelBoot, "iPhone9,") && !strings.HasPrefix(d.Firmwares[0].FirmwareType, "10.") {
+			t.Fatalf("%s can not run iOS %s", d.DeviceModelBoot, d.Firmwares[0].FirmwareType)
+		}
+	}
+
+	f, err := ioutil.TempFile("", "devices")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString(`{"platform": "ios", "devices": [{
+		"device_brand": "Apple", "device_model": "iPhone", "device_model_boot": "iPhone9,3",
+		"hardware_manufacturer": "Apple", "hardware_model": "D101AP", "firmware_brand": "iPhone OS",
+		"firmwares": [{"firmware_type": "10.2.1"}]
+	}]}`)
+	f.Close()
+
+	catalog, err := LoadDeviceCatalog(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	device, err := catalog.Device("trainer1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if device.DeviceModelBoot != "iPhone9,3" || device.FirmwareType != "10.2.1" || len(device.DeviceId) != 32 {
+		t.Fatalf("Unexpected device %v", device)
+	}
+}
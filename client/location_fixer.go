@@ -6,77 +6,98 @@ import (
 	"time"
 
 	"github.com/globalpokecache/POGOProtos-go"
+	"github.com/globalpokecache/pogobuf-go/helpers"
 )
 
+// locationFixer records a location fix about every second until ctx is done.
+// It reschedules itself on the clock instead of sleeping in a goroutine, so a
+// fake clock runs it in step with the rest of the session
 func (c *Instance) locationFixer(ctx context.Context) {
-	lastpos := []float64{c.player.Latitude(), c.player.Longitude()}
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		default:
-		}
-
-		t := getTimestamp(time.Now())
+	if ctx.Err() != nil {
+		return
+	}
+	c.locationFix()
+	next := time.Duration(900+c.fixerRand.Intn(50)) * time.Millisecond
+	c.clock.AfterFunc(next, func() {
+		c.locationFixer(ctx)
+	})
+}
 
-		moving := (lastpos[0] != c.player.Latitude()) || (lastpos[1] != c.player.Longitude())
-		lastpos[0] = c.player.Latitude()
-		lastpos[1] = c.player.Longitude()
-		if c.lastLocationFix == nil || moving || randFloat() > 0.85 {
-			c.player.SetAccuracy([]float64{5, 5, 5, 5, 10, 10, 10, 30, 30, 50, 65, math.Floor(randFloat()*(80-66)) + 66}[randInt(12)])
+func (c *Instance) locationFix() {
+	lastpos := c.fixerPosition
+	now := c.clock.Now()
+	t := getTimestamp(now)
 
-			junk := (randFloat() < 0.03)
-			fix := &protos.Signature_LocationFix{
-				Provider:       "fused",
-				Latitude:       360.0,
-				Longitude:      360.0,
-				Altitude:       0.0,
-				ProviderStatus: 3,
-				LocationType:   1,
-				Floor:          0,
-				Course:         -1,
-				Speed:          -1,
-			}
+	c.activity.observe(now, helpers.LatLng{Lat: c.player.Latitude(), Lng: c.player.Longitude()})
 
-			if !junk {
-				fix.Latitude = float32(c.player.Latitude())
-				fix.Longitude = float32(c.player.Longitude())
-				if c.player.Altitude() > 0 {
-					fix.Altitude = float32(c.player.Altitude())
-				} else {
-					fix.Altitude = float32(randTriang(300, 400, 350))
-				}
-			}
+	moving := (lastpos.Lat != c.player.Latitude()) || (lastpos.Lng != c.player.Longitude())
+	c.fixerPosition = helpers.LatLng{Lat: c.player.Latitude(), Lng: c.player.Longitude()}
+	if c.lastLocationFix == nil || moving || c.fixerRand.Float64() > 0.85 {
+		c.player.SetAccuracy([]float64{5, 5, 5, 5, 10, 10, 10, 30, 30, 50, 65, math.Floor(c.fixerRand.Float64()*(80-66)) + 66}[c.fixerRand.Intn(12)])
 
-			if randFloat() < 0.95 {
-				fix.Course = float32(randTriang(0.0, 359.0, float64(c.lastLocationCourse)))
-				fix.Speed = float32(randTriang(0.2, 4.25, 1))
-				c.lastLocationCourse = fix.Course
-			}
+		junk := (c.fixerRand.Float64() < 0.03)
+		fix := &protos.Signature_LocationFix{
+			Provider:       c.locationProvider(c.player.Accuracy()),
+			Latitude:       360.0,
+			Longitude:      360.0,
+			Altitude:       0.0,
+			ProviderStatus: 3,
+			LocationType:   1,
+			Floor:          0,
+			Course:         -1,
+			Speed:          -1,
+		}
 
-			if c.player.Accuracy() >= 65 {
-				fix.VerticalAccuracy = float32(randTriang(35, 100, 65))
-				fix.HorizontalAccuracy = float32([]float64{c.player.Accuracy(), 65, 65, 66 + (randFloat() * 14), 200}[randInt(5)])
-			} else if c.player.Accuracy() > 10 {
-				fix.HorizontalAccuracy = float32(c.player.Accuracy())
-				fix.VerticalAccuracy = float32([]float64{32, 48, 48, 64, 64, 96, 128}[randInt(7)])
+		if !junk {
+			fix.Latitude = float32(c.player.Latitude())
+			fix.Longitude = float32(c.player.Longitude())
+			if c.player.HasAltitude() {
+				fix.Altitude = float32(c.player.Altitude())
 			} else {
-				fix.HorizontalAccuracy = float32(c.player.Accuracy())
-				fix.VerticalAccuracy = float32([]float64{3, 4, 6, 6, 8, 12, 24}[randInt(7)])
+				fix.Altitude = float32(c.fixerRand.Triang(300, 400, 350))
 			}
+		}
 
-			fix.TimestampSnapshot = t - c.startedTime + uint64(-100+randInt(100))
+		if speed := c.player.Speed(); speed > 0 {
+			// GPS course and speed are noisy around the real movement
+			course := math.Mod(c.player.Course()+c.fixerRand.Triang(-5, 5, 0)+360, 360)
+			fix.Course = float32(course)
+			fix.Speed = float32(math.Max(0, c.fixerRand.Triang(speed*0.95, speed*1.05, speed)))
+			c.lastLocationCourse = fix.Course
+		} else if c.fixerRand.Float64() < 0.95 {
+			// Standing still the course barely changes and the speed is
+			// just the position jitter
+			fix.Course = float32(math.Mod(float64(c.lastLocationCourse)+c.fixerRand.Triang(-3, 3, 0)+360, 360))
+			fix.Speed = float32(c.fixerRand.Triang(0, 0.3, 0))
+			c.lastLocationCourse = fix.Course
+		}
 
-			for done := false; !done; {
-				select {
-				case c.locationFixes <- fix:
-					done = true
-				default:
-					<-c.locationFixes
-				}
-			}
+		if c.player.Accuracy() >= 65 {
+			fix.VerticalAccuracy = float32(c.fixerRand.Triang(35, 100, 65))
+			fix.HorizontalAccuracy = float32([]float64{c.player.Accuracy(), 65, 65, 66 + (c.fixerRand.Float64() * 14), 200}[c.fixerRand.Intn(5)])
+		} else if c.player.Accuracy() > 10 {
+			fix.HorizontalAccuracy = float32(c.player.Accuracy())
+			fix.VerticalAccuracy = float32([]float64{32, 48, 48, 64, 64, 96, 128}[c.fixerRand.Intn(7)])
+		} else {
+			fix.HorizontalAccuracy = float32(c.player.Accuracy())
+			fix.VerticalAccuracy = float32([]float64{3, 4, 6, 6, 8, 12, 24}[c.fixerRand.Intn(7)])
+		}
+
+		if fix.Provider == "network" {
+			// Network locations carry no altitude
+			fix.Altitude = 0
+			fix.VerticalAccuracy = 0
 		}
 
-		randSleep(900, 950)
+		fix.TimestampSnapshot = t - c.startedTime + uint64(-100+c.fixerRand.Intn(100))
+
+		for done := false; !done; {
+			select {
+			case c.locationFixes <- fix:
+				done = true
+			default:
+				<-c.locationFixes
+			}
+		}
 	}
 }
@@ -0,0 +1,260 @@
+package client
+
+import (
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/globalpokecache/POGOProtos-go"
+)
+
+// MapState holds the merged content of map cells across incremental
+// GET_MAP_OBJECTS responses, like the game client cache does. Cells are
+// requested with the CurrentTimestampMs of the last response for them so the
+// server only sends what changed; Merge applies those changes.
+type MapState struct {
+	sync.Mutex
+	cells map[uint64]*protos.MapCell
+}
+
+func NewMapState() *MapState {
+	return &MapState{
+		cells: map[uint64]*protos.MapCell{},
+	}
+}
+
+// SinceTimestamps returns the since timestamps to request the cells with,
+// zero for the cells not known yet
+func (m *MapState) SinceTimestamps(cellIDs []uint64) []int64 {
+	m.Lock()
+	defer m.Unlock()
+
+	since := make([]int64, len(cellIDs))
+	for i, id := range cellIDs {
+		if cell, ok := m.cells[id]; ok {
+			since[i] = cell.CurrentTimestampMs
+		}
+	}
+	return since
+}
+
+// Forget drops the cells, their next request will be a full one
+func (m *MapState) Forget(cellIDs []uint64) {
+	m.Lock()
+	for _, id := range cellIDs {
+		delete(m.cells, id)
+	}
+	m.Unlock()
+}
+
+// Retain drops every cell not in cellIDs, call it when the player moved to
+// another area
+func (m *MapState) Retain(cellIDs []uint64) {
+	keep := make(map[uint64]bool, len(cellIDs))
+	for _, id := range cellIDs {
+		keep[id] = true
+	}
+
+	m.Lock()
+	for id := range m.cells {
+		if !keep[id] {
+			delete(m.cells, id)
+		}
+	}
+	m.Unlock()
+}
+
+// Expire drops the cells last received before the timestamp in milliseconds
+func (m *MapState) Expire(beforeMs int64) {
+	m.Lock()
+	for id, cell := range m.cells {
+		if cell.CurrentTimestampMs < beforeMs {
+			delete(m.cells, id)
+		}
+	}
+	m.Unlock()
+}
+
+// Reset drops every cell
+func (m *MapState) Reset() {
+	m.Lock()
+	m.cells = map[uint64]*protos.MapCell{}
+	m.Unlock()
+}
+
+// Merge applies a response to the state. Cells not known yet are taken as
+// sent, known cells are updated with the objects in the response and lose the
+// ones listed as deleted or expired. Forget the cells first when they were
+// requested in full.
+func (m *MapState) Merge(resp *protos.GetMapObjectsResponse) {
+	if resp == nil {
+		return
+	}
+
+	m.Lock()
+	defer m.Unlock()
+
+	for _, cell := range resp.MapCells {
+		known, ok := m.cells[cell.S2CellId]
+		if !ok {
+			m.cells[cell.S2CellId] = copyMapCell(cell)
+			continue
+		}
+		m.cells[cell.S2CellId] = mergeMapCell(known, cell)
+	}
+}
+
+// Cells returns copies of the known cells in the order requested, cells never
+// received are left out
+func (m *MapState) Cells(cellIDs []uint64) []*protos.MapCell {
+	m.Lock()
+	defer m.Unlock()
+
+	var cells []*protos.MapCell
+	for _, id := range cellIDs {
+		if cell, ok := m.cells[id]; ok {
+			cells = append(cells, copyMapCell(cell))
+		}
+	}
+	return cells
+}
+
+// CellIDs returns the ids of every known cell
+func (m *MapState) CellIDs() []uint64 {
+	m.Lock()
+	defer m.Unlock()
+
+	ids := make([]uint64, 0, len(m.cells))
+	for id := range m.cells {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return ids[i] < ids[j]
+	})
+	return ids
+}
+
+func copyMapCell(cell *protos.MapCell) *protos.MapCell {
+	c := *cell
+	c.Forts = append([]*protos.FortData{}, cell.Forts...)
+	c.SpawnPoints = append([]*protos.SpawnPoint{}, cell.SpawnPoints...)
+	c.DeletedObjects = nil
+	c.FortSummaries = append([]*protos.FortSummary{}, cell.FortSummaries...)
+	c.DecimatedSpawnPoints = append([]*protos.SpawnPoint{}, cell.DecimatedSpawnPoints...)
+	c.WildPokemons = append([]*protos.WildPokemon{}, cell.WildPokemons...)
+	c.CatchablePokemons = append([]*protos.MapPokemon{}, cell.CatchablePokemons...)
+	c.NearbyPokemons = append([]*protos.NearbyPokemon{}, cell.NearbyPokemons...)
+	return &c
+}
+
+func mergeMapCell(known, update *protos.MapCell) *protos.MapCell {
+	merged := copyMapCell(known)
+	if update.CurrentTimestampMs > merged.CurrentTimestampMs {
+		merged.CurrentTimestampMs = update.CurrentTimestampMs
+	}
+	merged.IsTruncatedList = update.IsTruncatedList
+	now := merged.CurrentTimestampMs
+
+	deleted := map[string]bool{}
+	for _, id := range update.DeletedObjects {
+		deleted[id] = true
+	}
+	encounterDeleted := func(id uint64) bool {
+		return deleted[strconv.FormatUint(id, 10)]
+	}
+
+	forts := map[string]*protos.FortData{}
+	for _, f := range merged.Forts {
+		forts[f.Id] = f
+	}
+	for _, f := range update.Forts {
+		forts[f.Id] = f
+	}
+	merged.Forts = merged.Forts[:0]
+	for id, f := range forts {
+		if !deleted[id] {
+			merged.Forts = append(merged.Forts, f)
+		}
+	}
+	sort.Slice(merged.Forts, func(i, j int) bool {
+		return merged.Forts[i].Id < merged.Forts[j].Id
+	})
+
+	summaries := map[string]*protos.FortSummary{}
+	for _, f := range merged.FortSummaries {
+		summaries[f.FortSummaryId] = f
+	}
+	for _, f := range update.FortSummaries {
+		summaries[f.FortSummaryId] = f
+	}
+	merged.FortSummaries = merged.FortSummaries[:0]
+	for id, f := range summaries {
+		if !deleted[id] {
+			merged.FortSummaries = append(merged.FortSummaries, f)
+		}
+	}
+	sort.Slice(merged.FortSummaries, func(i, j int) bool {
+		return merged.FortSummaries[i].FortSummaryId < merged.FortSummaries[j].FortSummaryId
+	})
+
+	merged.SpawnPoints = mergeSpawnPoints(merged.SpawnPoints, update.SpawnPoints)
+	merged.DecimatedSpawnPoints = mergeSpawnPoints(merged.DecimatedSpawnPoints, update.DecimatedSpawnPoints)
+
+	wild := map[uint64]*protos.WildPokemon{}
+	for _, p := range merged.WildPokemons {
+		wild[p.EncounterId] = p
+	}
+	for _, p := range update.WildPokemons {
+		wild[p.EncounterId] = p
+	}
+	merged.WildPokemons = merged.WildPokemons[:0]
+	for id, p := range wild {
+		hidden := p.LastModifiedTimestampMs + int64(p.TimeTillHiddenMs)
+		if encounterDeleted(id) || (p.TimeTillHiddenMs > 0 && hidden <= now) {
+			continue
+		}
+		merged.WildPokemons = append(merged.WildPokemons, p)
+	}
+	sort.Slice(merged.WildPokemons, func(i, j int) bool {
+		return merged.WildPokemons[i].EncounterId < merged.WildPokemons[j].EncounterId
+	})
+
+	catchable := map[uint64]*protos.MapPokemon{}
+	for _, p := range merged.CatchablePokemons {
+		catchable[p.EncounterId] = p
+	}
+	for _, p := range update.CatchablePokemons {
+		catchable[p.EncounterId] = p
+	}
+	merged.CatchablePokemons = merged.CatchablePokemons[:0]
+	for id, p := range catchable {
+		if encounterDeleted(id) || (p.ExpirationTimestampMs > 0 && p.ExpirationTimestampMs <= now) {
+			continue
+		}
+		merged.CatchablePokemons = append(merged.CatchablePokemons, p)
+	}
+	sort.Slice(merged.CatchablePokemons, func(i, j int) bool {
+		return merged.CatchablePokemons[i].EncounterId < merged.CatchablePokemons[j].EncounterId
+	})
+
+	// Nearby pokemon depend on the player position, they are always sent whole
+	merged.NearbyPokemons = append([]*protos.NearbyPokemon{}, update.NearbyPokemons...)
+
+	return merged
+}
+
+func mergeSpawnPoints(known, update []*protos.SpawnPoint) []*protos.SpawnPoint {
+	type key struct{ lat, lng float64 }
+	seen := map[key]bool{}
+	for _, sp := range known {
+		seen[key{sp.Latitude, sp.Longitude}] = true
+	}
+	for _, sp := range update {
+		k := key{sp.Latitude, sp.Longitude}
+		if !seen[k] {
+			seen[k] = true
+			known = append(known, sp)
+		}
+	}
+	return known
+}
@@ -0,0 +1,56 @@
+package client
+
+import (
+	"testing"
+
+	"github.com/globalpokecache/POGOProtos-go"
+)
+
+func TestMapStateMerge(t *testing.T) {
+	state := NewMapState()
+
+	if since := state.SinceTimestamps([]uint64{1}); since[0] != 0 {
+		t.Fatal("Unknown cells must be requested in full")
+	}
+
+	state.Merge(&protos.GetMapObjectsResponse{MapCells: []*protos.MapCell{{
+		S2CellId:           1,
+		CurrentTimestampMs: 1000,
+		Forts:              []*protos.FortData{{Id: "a"}, {Id: "b"}},
+		CatchablePokemons: []*protos.MapPokemon{
+			{EncounterId: 10, ExpirationTimestampMs: 1500},
+			{EncounterId: 11, ExpirationTimestampMs: 9000},
+		},
+	}}})
+
+	if since := state.SinceTimestamps([]uint64{1, 2}); since[0] != 1000 || since[1] != 0 {
+		t.Fatalf("Unexpected since timestamps %v", since)
+	}
+
+	state.Merge(&protos.GetMapObjectsResponse{MapCells: []*protos.MapCell{{
+		S2CellId:           1,
+		CurrentTimestampMs: 2000,
+		Forts:              []*protos.FortData{{Id: "c"}},
+		DeletedObjects:     []string{"a"},
+	}}})
+
+	cells := state.Cells([]uint64{1, 2})
+	if len(cells) != 1 {
+		t.Fatalf("Expected 1 known cell, got %d", len(cells))
+	}
+	cell := cells[0]
+	if cell.CurrentTimestampMs != 2000 {
+		t.Fatal("Cell timestamp not updated")
+	}
+	if len(cell.Forts) != 2 || cell.Forts[0].Id != "b" || cell.Forts[1].Id != "c" {
+		t.Fatalf("Unexpected forts %v", cell.Forts)
+	}
+	if len(cell.CatchablePokemons) != 1 || cell.CatchablePokemons[0].EncounterId != 11 {
+		t.Fatalf("Expired pokemon kept %v", cell.CatchablePokemons)
+	}
+
+	state.Retain([]uint64{2})
+	if len(state.CellIDs()) != 0 {
+		t.Fatal("Cells out of the area must be forgotten")
+	}
+}
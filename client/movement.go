@@ -0,0 +1,255 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/globalpokecache/pogobuf-go/helpers"
+)
+
+// Interval between two position updates while moving
+const movementStep = time.Second
+
+// Distance in meters after which the ElevationProvider is asked again while
+// moving along legs without altitude
+const elevationStep = 20
+
+var ErrInvalidSpeed = errors.New("Speed must be positive")
+
+// Progress reports the player position while moving along a path
+type Progress struct {
+	Latitude  float64
+	Longitude float64
+	Course    float64
+	Speed     float64
+	// Traveled and Remaining are distances along the path in meters
+	Traveled  float64
+	Remaining float64
+	// Waypoint is the index of the path point the player walks to
+	Waypoint int
+	Arrived  bool
+}
+
+// WalkTo walks the player in a straight line to lat, lng at speed m/s. See
+// FollowPath.
+func (c *Instance) WalkTo(ctx context.Context, lat, lng, speed float64, progress func(Progress)) error {
+	return c.FollowPath(ctx, []helpers.LatLng{{Lat: lat, Lng: lng}}, speed, progress)
+}
+
+// FollowPath moves the player through the path points at speed m/s, updating
+// its position every second so calls made meanwhile and the location fixes see
+// the intermediate positions with a matching course and speed. progress, when
+// not nil, is called after every step and once on arrival. It blocks until the
+// last point is reached, ctx is done or another movement or SetPosition
+// interrupts it.
+func (c *Instance) FollowPath(ctx context.Context, path []helpers.LatLng, speed float64, progress func(Progress)) error {
+	if speed <= 0 {
+		return ErrInvalidSpeed
+	}
+
+	legs := make([]leg, len(path))
+	for i, p := range path {
+		legs[i] = leg{target: p, speed: speed}
+	}
+	return c.follow(ctx, legs, progress)
+}
+
+// leg is a part of a movement ending at target. When duration is set the leg
+// takes exactly that long, the player staying at target if already there.
+// Without duration nor speed the player is put at target at once.
+type leg struct {
+	target      helpers.LatLng
+	speed       float64
+	duration    time.Duration
+	altitude    float64
+	hasAltitude bool
+}
+
+func (c *Instance) follow(ctx context.Context, legs []leg, progress func(Progress)) error {
+	ctx, id := c.startMovement(ctx)
+	defer c.endMovement(id)
+
+	pos := helpers.LatLng{Lat: c.player.Latitude(), Lng: c.player.Longitude()}
+
+	remaining := 0.0
+	prev := pos
+	for _, l := range legs {
+		remaining += helpers.Distance(prev, l.target)
+		prev = l.target
+	}
+
+	var traveled float64
+	lookedUp := pos
+	for i, l := range legs {
+		from := pos
+		length := helpers.Distance(pos, l.target)
+		course := helpers.Bearing(pos, l.target)
+		startAltitude := c.player.Altitude()
+		if !c.player.HasAltitude() {
+			startAltitude = l.altitude
+		}
+
+		// advance moves the player to next and reports it
+		advance := func(next helpers.LatLng, speed float64) bool {
+			step := helpers.Distance(pos, next)
+			pos = next
+			traveled += step
+			remaining -= step
+			if remaining < 0 {
+				remaining = 0
+			}
+
+			if !c.moveTo(ctx, pos, course, speed) {
+				return false
+			}
+			if l.hasAltitude && length > 0 {
+				done := helpers.Distance(from, pos) / length
+				c.player.SetAltitude(startAltitude + (l.altitude-startAltitude)*done)
+			} else if !l.hasAltitude && c.options.ElevationProvider != nil && (helpers.Distance(lookedUp, pos) >= elevationStep || pos == l.target) {
+				c.lookupAltitude(ctx, pos.Lat, pos.Lng)
+				lookedUp = pos
+			}
+
+			if progress != nil {
+				progress(Progress{
+					Latitude:  pos.Lat,
+					Longitude: pos.Lng,
+					Course:    course,
+					Speed:     speed,
+					Traveled:  traveled,
+					Remaining: remaining,
+					Waypoint:  i,
+				})
+			}
+			return true
+		}
+
+		switch {
+		case l.duration > 0:
+			// Recorded leg, replayed on its own timing without jitter
+			speed := length / l.duration.Seconds()
+			if length < 0.01 {
+				course = c.player.Course()
+				speed = 0
+			}
+			for elapsed := time.Duration(0); elapsed < l.duration; {
+				wait := movementStep
+				if l.duration-elapsed < wait {
+					wait = l.duration - elapsed
+				}
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case <-c.clock.After(wait):
+				}
+				elapsed += wait
+
+				next := l.target
+				if elapsed < l.duration && length >= 0.01 {
+					next = helpers.Destination(from, course, length*float64(elapsed)/float64(l.duration))
+				}
+				if !advance(next, speed) {
+					return ctx.Err()
+				}
+			}
+
+		case l.speed <= 0:
+			// Start of a replay without approach speed, the player is put
+			// there like with SetPosition
+			if !advance(l.target, 0) {
+				return ctx.Err()
+			}
+
+		default:
+			for helpers.Distance(pos, l.target) >= 0.01 {
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case <-c.clock.After(movementStep):
+				}
+
+				// Pace varies a bit around the requested speed like a real
+				// walk
+				speed := c.moveRand.Triang(l.speed*0.9, l.speed*1.1, l.speed)
+				step := speed * movementStep.Seconds()
+				course = helpers.Bearing(pos, l.target)
+				next := l.target
+				if step < helpers.Distance(pos, l.target) {
+					next = helpers.Destination(pos, course, step)
+				}
+				if !advance(next, speed) {
+					return ctx.Err()
+				}
+			}
+		}
+	}
+
+	c.endMovement(id)
+
+	if progress != nil {
+		progress(Progress{
+			Latitude:  pos.Lat,
+			Longitude: pos.Lng,
+			Course:    c.player.Course(),
+			Traveled:  traveled,
+			Waypoint:  len(legs) - 1,
+			Arrived:   true,
+		})
+	}
+
+	return nil
+}
+
+// startMovement interrupts the ongoing movement and returns the context and
+// id of the new one
+func (c *Instance) startMovement(ctx context.Context) (context.Context, int64) {
+	ctx, cancel := context.WithCancel(ctx)
+
+	c.movementMutex.Lock()
+	defer c.movementMutex.Unlock()
+
+	if c.movementCancel != nil {
+		c.movementCancel()
+	}
+	c.movementCancel = cancel
+	c.movementID++
+
+	return ctx, c.movementID
+}
+
+// endMovement stops the player unless another movement took over meanwhile
+func (c *Instance) endMovement(id int64) {
+	c.movementMutex.Lock()
+	defer c.movementMutex.Unlock()
+
+	if c.movementID != id || c.movementCancel == nil {
+		return
+	}
+	c.movementCancel()
+	c.movementCancel = nil
+	c.player.SetMotion(c.player.Course(), 0)
+}
+
+func (c *Instance) stopMovement() {
+	c.movementMutex.Lock()
+	if c.movementCancel != nil {
+		c.movementCancel()
+		c.movementCancel = nil
+	}
+	c.movementMutex.Unlock()
+}
+
+// moveTo updates the player unless the movement was interrupted meanwhile
+func (c *Instance) moveTo(ctx context.Context, pos helpers.LatLng, course, speed float64) bool {
+	c.movementMutex.Lock()
+	defer c.movementMutex.Unlock()
+
+	if ctx.Err() != nil {
+		return false
+	}
+	c.player.SetLatitude(pos.Lat)
+	c.player.SetLongitude(pos.Lng)
+	c.player.SetMotion(course, speed)
+	return true
+}
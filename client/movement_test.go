@@ -0,0 +1,96 @@
+package client
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/globalpokecache/pogobuf-go/clock"
+	"github.com/globalpokecache/pogobuf-go/helpers"
+	"github.com/globalpokecache/pogobuf-go/route"
+)
+
+// deadSea is below sea level everywhere east of longitude 35.5
+type deadSea struct {
+	calls int
+}
+
+func (d *deadSea) Elevation(ctx context.Context, lat, lng float64) (float64, error) {
+	d.calls++
+	if lng > 35.5 {
+		return -430, nil
+	}
+	return 0, nil
+}
+
+func TestMovementAltitude(t *testing.T) {
+	fake := clock.NewFake(time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC))
+	fake.SetAutoAdvance(true)
+	provider := &deadSea{}
+	c := &Instance{
+		clock:    fake,
+		moveRand: newRandom(rand.NewSource(1)),
+		options:  Options{ElevationProvider: provider},
+	}
+
+	ctx := context.Background()
+	c.SetPosition(ctx, 31.5, 35.499, 0, 0)
+	if !c.player.HasAltitude() || c.player.Altitude() != 0 {
+		t.Fatal("Sea level altitude should be known")
+	}
+
+	target := helpers.Destination(helpers.LatLng{Lat: 31.5, Lng: 35.499}, 90, 200)
+	if err := c.WalkTo(ctx, target.Lat, target.Lng, 5, nil); err != nil {
+		t.Fatal(err)
+	}
+	if c.player.Altitude() != -430 {
+		t.Fatalf("Expected the altitude below sea level, got %v", c.player.Altitude())
+	}
+	if provider.calls < 5 {
+		t.Fatalf("Elevation asked %d times while walking 200m", provider.calls)
+	}
+}
+
+func TestFollowRouteReplay(t *testing.T) {
+	fake := clock.NewFake(time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC))
+	fake.SetAutoAdvance(true)
+	c := &Instance{clock: fake, moveRand: newRandom(rand.NewSource(1))}
+	c.player.SetLatitude(48)
+	c.player.SetLongitude(2)
+
+	// A 1 Hz track 10m per second, far from the player
+	start := helpers.LatLng{Lat: 48.5, Lng: 2.5}
+	recorded := time.Date(2016, 7, 6, 12, 0, 0, 0, time.UTC)
+	r := &route.Route{}
+	for i := 0; i < 5; i++ {
+		p := helpers.Destination(start, 0, float64(i)*10)
+		r.Waypoints = append(r.Waypoints, route.Waypoint{
+			Latitude:  p.Lat,
+			Longitude: p.Lng,
+			Time:      recorded.Add(time.Duration(i) * time.Second),
+		})
+	}
+
+	var steps []Progress
+	begin := fake.Now()
+	err := c.FollowRoute(context.Background(), r, RouteOptions{Scale: 2}, func(p Progress) {
+		steps = append(steps, p)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if elapsed := fake.Now().Sub(begin); elapsed != 2*time.Second {
+		t.Fatalf("Replay at twice the speed took %s, expected 2s", elapsed)
+	}
+	if steps[0].Speed != 0 || helpers.Distance(helpers.LatLng{Lat: steps[0].Latitude, Lng: steps[0].Longitude}, start) > 0.01 {
+		t.Fatalf("Expected a jump to the first waypoint, got %+v", steps[0])
+	}
+	for _, p := range steps[1 : len(steps)-1] {
+		if math.Abs(p.Speed-20) > 0.01 {
+			t.Fatalf("Expected the recorded pace of 20 m/s, got %v", p.Speed)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package client
+
+import (
+	"errors"
+
+	"github.com/globalpokecache/POGOProtos-go"
+)
+
+var ErrPlatformNotSupported = errors.New("Platform not supported")
+
+// Locale is the region of the app, sent with the GET_PLAYER requests and the
+// Android asset digest
+type Locale struct {
+	Country  string
+	Language string
+	Timezone string
+}
+
+// DefaultLocale is the locale of an app in the United States
+var DefaultLocale = Locale{Country: "US", Language: "en", Timezone: "America/Chicago"}
+
+// tag returns the locale as the Android app writes it, like en_US
+func (l Locale) tag() string {
+	return l.Language + "_" + l.Country
+}
+
+// Horizontal accuracy from which Android fixes come from the network provider
+const androidNetworkAccuracy = 65
+
+func devicePlatform(platform protos.Platform) string {
+	if platform == protos.Platform_ANDROID {
+		return DevicePlatformAndroid
+	}
+	return DevicePlatformIOS
+}
+
+// deviceInfoPlatform tells the platform of a device from the fields only its
+// app fills, it is empty when they are all missing
+func deviceInfoPlatform(device *protos.Signature_DeviceInfo) string {
+	switch {
+	case device.DeviceBrand == "Apple" || device.FirmwareBrand == "iPhone OS":
+		return DevicePlatformIOS
+	case device.AndroidBoardName != "" || device.FirmwareFingerprint != "":
+		return DevicePlatformAndroid
+	}
+	return ""
+}
+
+// playerRequest builds a GET_PLAYER in the locale of the options
+func (c *Instance) playerRequest() (*protos.Request, error) {
+	l := c.options.Locale
+	return c.GetPlayerRequest(l.Country, l.Language, l.Timezone)
+}
+
+// assetDigestRequest builds the GET_ASSET_DIGEST of the login, the iOS app
+// leaves the device fields empty while Android fills them
+func (c *Instance) assetDigestRequest() (*protos.Request, error) {
+	if c.options.Platform != protos.Platform_ANDROID {
+		return c.GetAssetDigestRequest(c.options.Platform, "", "", "", c.options.Version)
+	}
+
+	device := c.options.SignatureInfo.DeviceInfo
+	return c.GetAssetDigestRequest(c.options.Platform, device.HardwareManufacturer, device.HardwareModel, c.options.Locale.tag(), c.options.Version)
+}
+
+// locationProvider returns the provider of a location fix, coarse Android
+// fixes come from the network instead of the fused provider
+func (c *Instance) locationProvider(accuracy float64) string {
+	if c.options.Platform == protos.Platform_ANDROID && accuracy >= androidNetworkAccuracy {
+		return "network"
+	}
+	return "fused"
+}
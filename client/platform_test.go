@@ -0,0 +1,133 @@
+package client
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/globalpokecache/POGOProtos-go"
+	"github.com/golang/protobuf/proto"
+)
+
+var tutorialDone = []protos.TutorialState{
+	protos.TutorialState_LEGAL_SCREEN,
+	protos.TutorialState_AVATAR_SELECTION,
+	protos.TutorialState_POKEMON_CAPTURE,
+	protos.TutorialState_NAME_SELECTION,
+	protos.TutorialState_FIRST_TIME_EXPERIENCE_COMPLETE,
+}
+
+// firstRequest decodes the first request of type t sent to the server
+func (s *fakeServer) firstRequest(t protos.RequestType, msg proto.Message) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, e := range s.envelopes {
+		for _, r := range e.Requests {
+			if r.RequestType == t {
+				return proto.Unmarshal(r.RequestMessage, msg) == nil
+			}
+		}
+	}
+	return false
+}
+
+func TestPlatformLogin(t *testing.T) {
+	locale := Locale{Country: "FR", Language: "fr", Timezone: "Europe/Paris"}
+	login := []protos.RequestType{
+		protos.RequestType_GET_PLAYER,
+		protos.RequestType_DOWNLOAD_REMOTE_CONFIG_VERSION,
+		protos.RequestType_GET_ASSET_DIGEST,
+		protos.RequestType_GET_PLAYER_PROFILE,
+	}
+
+	tests := []struct {
+		platform protos.Platform
+		requests []protos.RequestType
+		device   bool
+		locale   string
+	}{
+		{protos.Platform_IOS, append(login, protos.RequestType_REGISTER_BACKGROUND_DEVICE), false, ""},
+		{protos.Platform_ANDROID, login, true, "fr_FR"},
+	}
+
+	// Both apps log in with the same sequence, the Android one only skips the
+	// Apple Watch lookup
+	for _, test := range tests {
+		c, server, _ := fakeSession(t, &Options{
+			SimulateApp: true,
+			Platform:    test.platform,
+			Locale:      locale,
+		})
+		server.tutorial = tutorialDone
+		initSession(t, c)
+		c.cancel()
+
+		if got := server.requests(); !reflect.DeepEqual(got, test.requests) {
+			t.Fatalf("%s: expected the requests %v, got %v", test.platform, test.requests, got)
+		}
+
+		var player protos.GetPlayerMessage
+		if !server.firstRequest(protos.RequestType_GET_PLAYER, &player) || player.PlayerLocale.Country != "FR" || player.PlayerLocale.Timezone != "Europe/Paris" {
+			t.Fatalf("%s: GET_PLAYER not in the locale of the options", test.platform)
+		}
+
+		var config protos.DownloadRemoteConfigVersionMessage
+		if !server.firstRequest(protos.RequestType_DOWNLOAD_REMOTE_CONFIG_VERSION, &config) || config.Platform != test.platform {
+			t.Fatalf("%s: remote config requested for %s", test.platform, config.Platform)
+		}
+
+		var digest protos.GetAssetDigestMessage
+		if !server.firstRequest(protos.RequestType_GET_ASSET_DIGEST, &digest) {
+			t.Fatalf("%s: missing GET_ASSET_DIGEST", test.platform)
+		}
+		var manufacturer, model string
+		if test.device {
+			manufacturer = c.options.SignatureInfo.DeviceInfo.HardwareManufacturer
+			model = c.options.SignatureInfo.DeviceInfo.HardwareModel
+		}
+		if digest.Platform != test.platform || digest.DeviceManufacturer != manufacturer || digest.DeviceModel != model || digest.Locale != test.locale {
+			t.Fatalf("%s: unexpected asset digest %v", test.platform, digest)
+		}
+	}
+}
+
+func TestPlatformDeviceInfo(t *testing.T) {
+	ios, _ := IOSCatalog.Device("device")
+	android, _ := AndroidCatalog.Device("device")
+
+	opts := &Options{AuthProvider: fakeAuth{"device"}, HashProvider: &fakeHash{}, Platform: protos.Platform_ANDROID}
+	opts.SignatureInfo.DeviceInfo = ios
+	if _, err := New(opts); err == nil {
+		t.Fatal("Expected an iOS device to be rejected on Android")
+	}
+
+	opts.SignatureInfo.DeviceInfo = android
+	if _, err := New(opts); err != nil {
+		t.Fatal(err)
+	}
+
+	opts.Platform = protos.Platform_IOS
+	if _, err := New(opts); err == nil {
+		t.Fatal("Expected an Android device to be rejected on iOS")
+	}
+}
+
+func TestLocationProvider(t *testing.T) {
+	tests := []struct {
+		platform protos.Platform
+		accuracy float64
+		provider string
+	}{
+		{protos.Platform_IOS, 5, "fused"},
+		{protos.Platform_IOS, 200, "fused"},
+		{protos.Platform_ANDROID, 10, "fused"},
+		{protos.Platform_ANDROID, 64, "fused"},
+		{protos.Platform_ANDROID, 65, "network"},
+		{protos.Platform_ANDROID, 200, "network"},
+	}
+	for _, test := range tests {
+		c := &Instance{options: Options{Platform: test.platform}}
+		if got := c.locationProvider(test.accuracy); got != test.provider {
+			t.Fatalf("%s at %vm: expected %s, got %s", test.platform, test.accuracy, test.provider, got)
+		}
+	}
+}
@@ -1,15 +1,17 @@
 package client
 
 import (
-	"sync"
-
 	"context"
-	"googlemaps.github.io/maps"
+	"sync"
 )
 
 type Player struct {
 	sync.RWMutex
 	lat, lng, accu, alt float64
+	// hasAlt is false until the altitude is known, 0 being a valid one
+	hasAlt bool
+	// course in degrees and speed in m/s while moving, speed is 0 otherwise
+	course, speed float64
 }
 
 func (p *Player) SetLatitude(l float64) {
@@ -33,6 +35,23 @@ func (p *Player) SetAccuracy(l float64) {
 func (p *Player) SetAltitude(l float64) {
 	p.Lock()
 	p.alt = l
+	p.hasAlt = true
+	p.Unlock()
+}
+
+// ClearAltitude forgets the altitude, like after moving somewhere unknown
+func (p *Player) ClearAltitude() {
+	p.Lock()
+	p.alt = 0
+	p.hasAlt = false
+	p.Unlock()
+}
+
+// SetMotion sets the course and speed reported on the location fixes
+func (p *Player) SetMotion(course, speed float64) {
+	p.Lock()
+	p.course = course
+	p.speed = speed
 	p.Unlock()
 }
 
@@ -60,33 +79,61 @@ func (p *Player) Altitude() float64 {
 	return p.alt
 }
 
+// HasAltitude reports whether the altitude is known
+func (p *Player) HasAltitude() bool {
+	p.RLock()
+	defer p.RUnlock()
+	return p.hasAlt
+}
+
+func (p *Player) Course() float64 {
+	p.RLock()
+	defer p.RUnlock()
+	return p.course
+}
+
+func (p *Player) Speed() float64 {
+	p.RLock()
+	defer p.RUnlock()
+	return p.speed
+}
+
+// SetPosition teleports the player, stopping any ongoing movement. With an alt
+// of 0 the altitude comes from the ElevationProvider, it stays unknown without
+// one.
 func (c *Instance) SetPosition(ctx context.Context, lat, lon, accu, alt float64) error {
 	moved := false
 	if lat != c.player.Latitude() || lon != c.player.Longitude() {
 		moved = true
 	}
-	if alt > 0 {
+	if alt != 0 {
 		c.player.SetAltitude(alt)
-	} else if c.options.GoogleMapsKey != "" && moved {
-		cli, err := maps.NewClient(maps.WithAPIKey(c.options.GoogleMapsKey))
-		if err == nil {
-			r := &maps.ElevationRequest{
-				Locations: []maps.LatLng{
-					{lat, lon},
-				},
-			}
-			resp, err := cli.Elevation(ctx, r)
-			if err == nil && len(resp) > 0 {
-				c.player.SetAltitude(resp[0].Elevation)
-			}
-		}
+	} else if moved || !c.player.HasAltitude() {
+		c.lookupAltitude(ctx, lat, lon)
 	}
 
+	c.stopMovement()
 	c.player.SetLatitude(lat)
 	c.player.SetLongitude(lon)
+	c.player.SetMotion(c.player.Course(), 0)
 	if accu > 0 {
 		c.player.SetAccuracy(accu)
 	}
 
 	return nil
 }
+
+// lookupAltitude sets the player altitude from the ElevationProvider, leaving
+// it unknown when there is no data for the place
+func (c *Instance) lookupAltitude(ctx context.Context, lat, lon float64) {
+	if c.options.ElevationProvider == nil {
+		c.player.ClearAltitude()
+		return
+	}
+	elevation, err := c.options.ElevationProvider.Elevation(ctx, lat, lon)
+	if err != nil {
+		c.player.ClearAltitude()
+		return
+	}
+	c.player.SetAltitude(elevation)
+}
@@ -0,0 +1,72 @@
+package client
+
+import (
+	crand "crypto/rand"
+	"encoding/binary"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// random is the goroutine safe generator behind every value an instance
+// makes up, so a fixed seed reproduces a session
+type random struct {
+	sync.Mutex
+	r *rand.Rand
+}
+
+// newRandom uses src, or a source seeded from crypto/rand when it is nil
+func newRandom(src rand.Source) *random {
+	if src == nil {
+		src = rand.NewSource(randomSeed())
+	}
+	return &random{r: rand.New(src)}
+}
+
+func randomSeed() int64 {
+	var b [8]byte
+	if _, err := crand.Read(b[:]); err != nil {
+		return time.Now().UnixNano()
+	}
+	return int64(binary.LittleEndian.Uint64(b[:]))
+}
+
+// split returns a generator seeded from r. Consumers running on their own
+// goroutine each get one, so their draws do not depend on the interleaving
+func (r *random) split() *random {
+	r.Lock()
+	defer r.Unlock()
+	return &random{r: rand.New(rand.NewSource(r.r.Int63()))}
+}
+
+func (r *random) Intn(n int) int {
+	r.Lock()
+	defer r.Unlock()
+	return r.r.Intn(n)
+}
+
+func (r *random) Float64() float64 {
+	r.Lock()
+	defer r.Unlock()
+	return r.r.Float64()
+}
+
+func (r *random) Read(p []byte) (int, error) {
+	r.Lock()
+	defer r.Unlock()
+	return r.r.Read(p)
+}
+
+// Triang returns a value of the triangular distribution between lower and
+// upper peaking at mode
+func (r *random) Triang(lower, upper, mode float64) float64 {
+	var c = (mode - lower) / (upper - lower)
+	var u = r.Float64()
+
+	if u <= c {
+		return lower + math.Sqrt(u*(upper-lower)*(mode-lower))
+	}
+
+	return upper - math.Sqrt((1-u)*(upper-lower)*(upper-mode))
+}
@@ -7,12 +7,11 @@ import (
 	"time"
 
 	"github.com/globalpokecache/POGOProtos-go"
-	"github.com/globalpokecache/pogobuf-go/pcrypt"
+	"github.com/globalpokecache/pogobuf-go/hash"
 	"github.com/golang/protobuf/proto"
 )
 
 const defaultURL = "https://pgorelease.nianticlabs.com/plfe/rpc"
-const downloadSettingsHash = "f43e9e403f233d4541feda9816e9d6085bccb087"
 
 type SignatureInfo struct {
 	DeviceInfo *protos.Signature_DeviceInfo
@@ -57,21 +56,20 @@ func (c *Instance) getRequestId() int64 {
 
 var randAccuSeed = []int{5, 5, 5, 5, 10, 10, 10, 30, 30, 50, 65}
 
-func (c *Instance) requestThrottle(ctx context.Context) {
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		default:
-		}
-		c.waitRequest <- struct{}{}
-		time.Sleep(c.options.MinRequestInterval)
+// throttle waits until MinRequestInterval has passed since the previous request
+func (c *Instance) throttle() {
+	c.requestMutex.Lock()
+	defer c.requestMutex.Unlock()
+
+	if wait := c.nextRequest.Sub(c.clock.Now()); wait > 0 {
+		c.clock.Sleep(wait)
 	}
+	c.nextRequest = c.clock.Now().Add(c.options.MinRequestInterval)
 }
 
 func (c *Instance) call(ctx context.Context, requests []*protos.Request, prs []*protos.RequestEnvelope_PlatformRequest) (*protos.ResponseEnvelope, error) {
 	// interval between requests
-	<-c.waitRequest
+	c.throttle()
 
 	var respErr error
 	var responseEnvelope *protos.ResponseEnvelope
@@ -82,8 +80,8 @@ func (c *Instance) call(ctx context.Context, requests []*protos.Request, prs []*
 	if randAccu == 0 {
 		accuSeed := make([]int, len(randAccuSeed))
 		copy(accuSeed, randAccuSeed)
-		accuSeed = append(accuSeed, randInt(80-66)+66)
-		randAccu = float64(accuSeed[randInt(len(accuSeed))])
+		accuSeed = append(accuSeed, c.rand.Intn(80-66)+66)
+		randAccu = float64(accuSeed[c.rand.Intn(len(accuSeed))])
 	}
 
 	requestEnvelope := &protos.RequestEnvelope{
@@ -96,7 +94,7 @@ func (c *Instance) call(ctx context.Context, requests []*protos.Request, prs []*
 	} else {
 		var unk2 int32
 		if c.options.AuthProvider.Type() == "ptc" {
-			unk2 = []int32{2, 8, 21, 21, 21, 28, 37, 56, 59, 59, 59}[randInt(11)]
+			unk2 = []int32{2, 8, 21, 21, 21, 28, 37, 56, 59, 59, 59}[c.rand.Intn(11)]
 		}
 
 		requestEnvelope.AuthInfo = &protos.RequestEnvelope_AuthInfo{
@@ -158,26 +156,32 @@ func (c *Instance) call(ctx context.Context, requests []*protos.Request, prs []*
 	}
 
 	for i := 0; i <= c.options.MaxTries; i++ {
-		time.Sleep(time.Duration(i*300) * time.Millisecond)
+		c.clock.Sleep(time.Duration(i*300) * time.Millisecond)
 
-		t := getTimestamp(time.Now())
+		t := getTimestamp(c.clock.Now())
 
 		sinceStart := (t - c.startedTime)
 
 		var sensorTS uint64
 		if c.lastLocationFix != nil {
 			requestEnvelope.MsSinceLastLocationfix = int64(t - (c.startedTime + lastLocFixTime))
-			sensorTS = lastLocFixTime - uint64(-800+randInt(800))
+			sensorTS = lastLocFixTime - uint64(-800+c.rand.Intn(800))
 		} else {
 			requestEnvelope.MsSinceLastLocationfix = -1
-			sensorTS = sinceStart - uint64(100+randInt(100))
+			sensorTS = sinceStart - uint64(100+c.rand.Intn(100))
 		}
 
 		requestEnvelope.Longitude = long
 		requestEnvelope.Latitude = lat
 		requestEnvelope.Accuracy = randAccu
 
-		locHash1, locHash2, requestHash, err := c.options.HashProvider.Hash(
+		hashFunc := c.options.HashProvider.Hash
+		if hp, ok := c.options.HashProvider.(hash.ContextProvider); ok {
+			hashFunc = func(authTicket, sessionData []byte, latitude, longitude, accuracy float64, timestamp uint64, requests [][]byte) (uint32, uint32, []uint64, error) {
+				return hp.HashContext(ctx, authTicket, sessionData, latitude, longitude, accuracy, timestamp, requests)
+			}
+		}
+		locHash1, locHash2, requestHash, err := hashFunc(
 			ticket,
 			c.sessionHash,
 			lat,
@@ -190,52 +194,30 @@ func (c *Instance) call(ctx context.Context, requests []*protos.Request, prs []*
 			return nil, fmt.Errorf("Hash provider failed to hash: %s", err)
 		}
 
-		uk25, err := getUnk25(c.options.Version)
-		if err != nil {
-			return nil, err
-		}
-
 		signature := &protos.Signature{
 			LocationHash1:       int32(locHash1),
 			LocationHash2:       int32(locHash2),
 			SessionHash:         c.sessionHash,
 			Timestamp:           t,
 			TimestampSinceStart: sinceStart,
-			Unknown25:           uk25,
-			ActivityStatus: &protos.Signature_ActivityStatus{
-				Stationary: true,
-			},
+			Unknown25:           c.version.Unknown25,
+			ActivityStatus:      c.Activity().Activity.status(),
 		}
 
-		signature.SensorInfo = []*protos.Signature_SensorInfo{
-			{
-				TimestampSnapshot:   sensorTS,
-				LinearAccelerationX: randTriang(-1.7, 1.2, 0),
-				LinearAccelerationY: randTriang(-1.4, 1.9, 0),
-				LinearAccelerationZ: randTriang(-1.4, .9, 0),
-				AttitudePitch:       randTriang(-1.5, 1.5, 0.4),
-				AttitudeYaw:         randTriang(-3.1, 3.1, .198),
-				AttitudeRoll:        randTriang(-2.8, 3.04, 0),
-				RotationRateX:       randTriang(-4.7, 3.9, 0),
-				RotationRateY:       randTriang(-4.7, 4.3, 0),
-				RotationRateZ:       randTriang(-4.7, 6.5, 0),
-				GravityX:            randTriang(-1, 1, 0),
-				GravityY:            randTriang(-1, 1, -.2),
-				GravityZ:            randTriang(-1, .7, -0.7),
-				Status:              3,
-			},
-		}
-
-		if len(signature.SensorInfo) > 0 {
-			if c.rpcID == 2 {
-				signature.SensorInfo[0].MagneticFieldAccuracy = -1
-			} else {
-				signature.SensorInfo[0].MagneticFieldX = randTriang(-54, 50, 0)
-				signature.SensorInfo[0].MagneticFieldY = randTriang(-51, 57, -4.8)
-				signature.SensorInfo[0].MagneticFieldZ = randTriang(-56, 43, -30)
-				signature.SensorInfo[0].MagneticFieldAccuracy = []int32{-1, 1, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2}[randInt(8)]
-			}
+		sensor := c.options.SensorModel.Sample(SensorState{
+			Time:      c.clock.Now(),
+			Latitude:  c.player.Latitude(),
+			Longitude: c.player.Longitude(),
+			Course:    c.player.Course(),
+			Speed:     c.player.Speed(),
+		})
+		sensor.TimestampSnapshot = sensorTS
+		if c.rpcID == 2 {
+			// The magnetometer is not calibrated yet on the first request
+			sensor.MagneticFieldX, sensor.MagneticFieldY, sensor.MagneticFieldZ = 0, 0, 0
+			sensor.MagneticFieldAccuracy = -1
 		}
+		signature.SensorInfo = []*protos.Signature_SensorInfo{sensor}
 
 		if requests != nil && len(requests) > 0 {
 			signature.RequestHash = requestHash
@@ -251,8 +233,13 @@ func (c *Instance) call(ctx context.Context, requests []*protos.Request, prs []*
 			return nil, errors.New("Failed to marshal the request signature")
 		}
 
+		encrypted, err := c.cipher.Encrypt(signatureProto, uint32(signature.TimestampSinceStart))
+		if err != nil {
+			return nil, fmt.Errorf("Failed to encrypt the request signature: %s", err)
+		}
+
 		requestMessage, err := proto.Marshal(&protos.SendEncryptedSignatureRequest{
-			EncryptedSignature: pcrypt.Encrypt(signatureProto, uint32(signature.TimestampSinceStart)),
+			EncryptedSignature: encrypted,
 		})
 		if err != nil {
 			return nil, errors.New("Failed to marshal request message")
@@ -333,7 +320,7 @@ func (c *Instance) CallWithPlatformRequests(ctx context.Context, requests []*pro
 }
 
 func (c *Instance) shouldAddPtr8(requests []*protos.Request) bool {
-	r := randFloat()
+	r := c.rand.Float64()
 	if len(requests) == 0 {
 		return false
 	}
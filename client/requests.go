@@ -50,10 +50,10 @@ func (c *Instance) DownloadRemoteConfigVersion(ctx context.Context, platform pro
 
 func (c *Instance) GetAssetDigestRequest(platform protos.Platform, manufacturer, model, locale string, appVersion int) (*protos.Request, error) {
 	msg, err := proto.Marshal(&protos.GetAssetDigestMessage{
-		Platform:           protos.Platform_IOS,
-		DeviceManufacturer: "",
-		DeviceModel:        "",
-		Locale:             "",
+		Platform:           platform,
+		DeviceManufacturer: manufacturer,
+		DeviceModel:        model,
+		Locale:             locale,
 		AppVersion:         uint32(appVersion),
 	})
 	if err != nil {
@@ -327,7 +327,14 @@ func (c *Instance) CheckAwardedBadges(ctx context.Context) (*protos.CheckAwarded
 	return &checkAwardedBadges, nil
 }
 
+// GetMapObjectsRequest builds a GET_MAP_OBJECTS request, a nil
+// sinceTimestampMs requests the cells since the last response the instance
+// received for them
 func (c *Instance) GetMapObjectsRequest(cellIDs []uint64, sinceTimestampMs []int64) (*protos.Request, error) {
+	if sinceTimestampMs == nil {
+		sinceTimestampMs = c.mapState.SinceTimestamps(cellIDs)
+	}
+
 	msg, err := proto.Marshal(&protos.GetMapObjectsMessage{
 		CellId:           cellIDs,
 		SinceTimestampMs: sinceTimestampMs,
@@ -344,7 +351,17 @@ func (c *Instance) GetMapObjectsRequest(cellIDs []uint64, sinceTimestampMs []int
 	}, nil
 }
 
+// GetMapObjects requests the cells and merges the response in the instance
+// MapState. With a nil sinceTimestampMs only the changes since the last scan
+// of every cell are returned, merge them in a MapState of your own or read
+// MapCells. The other known cells are kept, batches of an area can be
+// requested in turn.
 func (c *Instance) GetMapObjects(ctx context.Context, cellIDs []uint64, sinceTimestampMs []int64) (*protos.GetMapObjectsResponse, error) {
+	c.expireMapState()
+	if sinceTimestampMs == nil {
+		sinceTimestampMs = c.mapState.SinceTimestamps(cellIDs)
+	}
+
 	request, err := c.GetMapObjectsRequest(cellIDs, sinceTimestampMs)
 	if err != nil {
 		return nil, err
@@ -368,6 +385,8 @@ func (c *Instance) GetMapObjects(ctx context.Context, cellIDs []uint64, sinceTim
 
 	debugProto("MapObjects", &getMapObjects)
 
+	c.mergeMapObjects(cellIDs, sinceTimestampMs, &getMapObjects)
+
 	return &getMapObjects, nil
 }
 
@@ -506,6 +525,10 @@ func (c *Instance) EncounterRequest(eid uint64, spawnPoint string) (*protos.Requ
 }
 
 func (c *Instance) Encounter(ctx context.Context, eid uint64, spawnPoint string) (*protos.EncounterResponse, error) {
+	if err := c.waitCooldown(ctx); err != nil {
+		return nil, err
+	}
+
 	request, err := c.EncounterRequest(eid, spawnPoint)
 	if err != nil {
 		return nil, err
@@ -529,6 +552,7 @@ func (c *Instance) Encounter(ctx context.Context, eid uint64, spawnPoint string)
 		err = fmt.Errorf("Failed to call ENCOUNTER: %s", err)
 		return nil, err
 	}
+	c.actionDone()
 
 	return &encounter, nil
 }
@@ -554,6 +578,10 @@ func (c *Instance) CatchPokemonRequest(eid uint64, spawnPoint string, iid protos
 }
 
 func (c *Instance) CatchPokemon(ctx context.Context, eid uint64, spawnPoint string, iid protos.ItemId, nrs float64, nhp float64, hit bool, spin float64) (*protos.CatchPokemonResponse, error) {
+	if err := c.waitCooldown(ctx); err != nil {
+		return nil, err
+	}
+
 	request, err := c.CatchPokemonRequest(eid, spawnPoint, iid, nrs, nhp, hit, spin)
 	if err != nil {
 		return nil, err
@@ -574,6 +602,7 @@ func (c *Instance) CatchPokemon(ctx context.Context, eid uint64, spawnPoint stri
 	if err != nil {
 		return nil, fmt.Errorf("Failed to call CATCH_POKEMON: %s", err)
 	}
+	c.actionDone()
 
 	return &catchResult, nil
 }
@@ -675,6 +704,10 @@ func (c *Instance) FortSearchRequest(fortid string, lat, lon float64) (*protos.R
 }
 
 func (c *Instance) FortSearch(ctx context.Context, fortid string, lat, lon float64) (*protos.FortSearchResponse, error) {
+	if err := c.waitCooldown(ctx); err != nil {
+		return nil, err
+	}
+
 	request, err := c.FortSearchRequest(fortid, lat, lon)
 	if err != nil {
 		return nil, err
@@ -699,6 +732,7 @@ func (c *Instance) FortSearch(ctx context.Context, fortid string, lat, lon float
 	if err != nil {
 		return nil, fmt.Errorf("Failed to call FORT_SEARCH: %s", err)
 	}
+	c.actionDone()
 
 	return &search, nil
 }
@@ -0,0 +1,50 @@
+package client
+
+import (
+	"context"
+	"time"
+
+	"github.com/globalpokecache/pogobuf-go/helpers"
+	"github.com/globalpokecache/pogobuf-go/route"
+)
+
+// RouteOptions sets how FollowRoute moves along a route
+type RouteOptions struct {
+	// Speed in m/s, used when Scale is 0 or the route has no timestamps
+	Speed float64
+	// Scale replays the recorded timestamps, 1 at real speed, 2 twice as fast
+	Scale float64
+}
+
+// FollowRoute moves the player along an imported route like FollowPath. Timed
+// routes are replayed with their recorded timestamps divided by opts.Scale
+// when it is set. The first waypoint is then walked to at opts.Speed, or
+// jumped to at once when it is 0. The player altitude follows the waypoint
+// elevations when known.
+func (c *Instance) FollowRoute(ctx context.Context, r *route.Route, opts RouteOptions, progress func(Progress)) error {
+	if len(r.Waypoints) == 0 {
+		return route.ErrNoWaypoints
+	}
+
+	replay := opts.Scale > 0 && r.Timed()
+	if !replay && opts.Speed <= 0 {
+		return ErrInvalidSpeed
+	}
+
+	legs := make([]leg, len(r.Waypoints))
+	for i, w := range r.Waypoints {
+		legs[i] = leg{
+			target:      helpers.LatLng{Lat: w.Latitude, Lng: w.Longitude},
+			speed:       opts.Speed,
+			altitude:    w.Elevation,
+			hasAltitude: w.HasElevation,
+		}
+		if replay && i > 0 {
+			elapsed := w.Time.Sub(r.Waypoints[i-1].Time)
+			legs[i].speed = 0
+			legs[i].duration = time.Duration(float64(elapsed) / opts.Scale)
+		}
+	}
+
+	return c.follow(ctx, legs, progress)
+}
@@ -0,0 +1,188 @@
+package client
+
+import (
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/globalpokecache/POGOProtos-go"
+)
+
+// SensorState is the player motion the sensor readings have to agree with
+type SensorState struct {
+	Time      time.Time
+	Latitude  float64
+	Longitude float64
+	// Course in degrees and Speed in m/s, Speed is 0 while standing still
+	Course float64
+	Speed  float64
+}
+
+// SensorModel produces the motion sensor readings sent on the signatures. The
+// timestamp snapshot is filled by the caller.
+type SensorModel interface {
+	Sample(state SensorState) *protos.Signature_SensorInfo
+}
+
+const (
+	// Seconds the hand takes to settle back to its usual pose
+	attitudeSettle = 4.0
+	// Usual pitch of a phone held in front of the player, radians
+	handheldPitch = 0.75
+	// Steps per second while walking
+	stepFrequency = 1.8
+	// Speeds in m/s from which the player is taken as running or in a vehicle
+	runningSpeed = 3.5
+	vehicleSpeed = 7.0
+	// Earth dipole field strength at the equator, micro tesla
+	earthField = 30.0
+)
+
+// HandheldSensors models a phone held in hand. The attitude drifts slowly and
+// follows the course while moving, gravity and the magnetic field are derived
+// from it, the rotation rate from its changes, and the acceleration matches
+// the steps or vehicle vibration at the player speed.
+type HandheldSensors struct {
+	sync.Mutex
+	rand             *random
+	started          bool
+	last             time.Time
+	pitch, roll, yaw float64
+	speed            float64
+	phase            float64
+	// Hard iron offset of the device magnetometer
+	magnetOffset [3]float64
+}
+
+// NewHandheldSensors uses src for the hand movements and noise, a seeded
+// source when it is nil
+func NewHandheldSensors(src rand.Source) *HandheldSensors {
+	return &HandheldSensors{rand: newRandom(src)}
+}
+
+func (s *HandheldSensors) Sample(state SensorState) *protos.Signature_SensorInfo {
+	s.Lock()
+	defer s.Unlock()
+
+	if !s.started {
+		s.started = true
+		s.last = state.Time
+		s.pitch = s.rand.Triang(handheldPitch-0.25, handheldPitch+0.25, handheldPitch)
+		s.roll = s.rand.Triang(-0.15, 0.15, 0)
+		s.yaw = s.rand.Triang(-math.Pi, math.Pi, 0)
+		if state.Speed > 0 {
+			s.yaw = courseToYaw(state.Course)
+		}
+		s.speed = state.Speed
+		s.phase = s.rand.Float64() * 2 * math.Pi
+		s.magnetOffset = [3]float64{s.rand.Triang(-8, 8, 0), s.rand.Triang(-8, 8, 0), s.rand.Triang(-8, 8, 0)}
+	}
+
+	dt := state.Time.Sub(s.last).Seconds()
+	if dt < 0 {
+		dt = 0
+	} else if dt > 30 {
+		dt = 30
+	}
+	s.last = state.Time
+
+	// The attitude relaxes towards the usual pose with some hand jitter, the
+	// yaw towards the course while moving
+	pitch, roll, yaw := s.pitch, s.roll, s.yaw
+	settle := 1 - math.Exp(-dt/attitudeSettle)
+	jitter := math.Sqrt(dt)
+	s.pitch += (handheldPitch-s.pitch)*settle + s.rand.Triang(-0.05, 0.05, 0)*jitter
+	s.roll += -s.roll*settle + s.rand.Triang(-0.04, 0.04, 0)*jitter
+	if state.Speed > 0 {
+		s.yaw += angleDiff(courseToYaw(state.Course), s.yaw) * settle
+	}
+	s.yaw = wrapAngle(s.yaw + s.rand.Triang(-0.03, 0.03, 0)*jitter)
+
+	info := &protos.Signature_SensorInfo{
+		AttitudePitch: s.pitch,
+		AttitudeRoll:  s.roll,
+		AttitudeYaw:   s.yaw,
+		Status:        3,
+	}
+
+	gyroNoise := func() float64 { return s.rand.Triang(-0.02, 0.02, 0) }
+	if dt > 0 {
+		info.RotationRateX = (s.pitch-pitch)/dt + gyroNoise()
+		info.RotationRateY = (s.roll-roll)/dt + gyroNoise()
+		info.RotationRateZ = angleDiff(s.yaw, yaw)/dt + gyroNoise()
+	} else {
+		info.RotationRateX, info.RotationRateY, info.RotationRateZ = gyroNoise(), gyroNoise(), gyroNoise()
+	}
+
+	gravity := toDevice([3]float64{0, 0, -1}, s.pitch, s.roll, s.yaw)
+	info.GravityX, info.GravityY, info.GravityZ = gravity[0], gravity[1], gravity[2]
+
+	// User acceleration in g, the body bounces up and down on every step
+	var bounce, vibration float64
+	switch {
+	case state.Speed <= 0:
+		vibration = 0.01
+	case state.Speed < runningSpeed:
+		bounce = 0.15 * math.Min(state.Speed/1.4, 2)
+		vibration = 0.02
+	case state.Speed < vehicleSpeed:
+		bounce = 0.5
+		vibration = 0.05
+	default:
+		vibration = 0.04
+	}
+	s.phase = math.Mod(s.phase+dt*stepFrequency*2*math.Pi, 2*math.Pi)
+	vertical := -bounce * math.Sin(s.phase)
+	var forward float64
+	if dt > 0 {
+		forward = (state.Speed - s.speed) / dt / 9.81
+	}
+	s.speed = state.Speed
+	heading := toDevice([3]float64{math.Sin(-s.yaw), math.Cos(-s.yaw), 0}, s.pitch, s.roll, s.yaw)
+	noise := func() float64 { return s.rand.Triang(-vibration, vibration, 0) }
+	info.LinearAccelerationX = gravity[0]*vertical + heading[0]*forward + noise()
+	info.LinearAccelerationY = gravity[1]*vertical + heading[1]*forward + noise()
+	info.LinearAccelerationZ = gravity[2]*vertical + heading[2]*forward + noise()
+
+	// Dipole approximation of the earth field, pointing north and down on the
+	// northern hemisphere
+	lat := state.Latitude * math.Pi / 180
+	field := toDevice([3]float64{0, earthField * math.Cos(lat), -2 * earthField * math.Sin(lat)}, s.pitch, s.roll, s.yaw)
+	info.MagneticFieldX = field[0] + s.magnetOffset[0] + s.rand.Triang(-0.5, 0.5, 0)
+	info.MagneticFieldY = field[1] + s.magnetOffset[1] + s.rand.Triang(-0.5, 0.5, 0)
+	info.MagneticFieldZ = field[2] + s.magnetOffset[2] + s.rand.Triang(-0.5, 0.5, 0)
+	info.MagneticFieldAccuracy = 2
+
+	return info
+}
+
+// courseToYaw turns a compass course into a counterclockwise yaw in radians
+func courseToYaw(course float64) float64 {
+	return wrapAngle(-course * math.Pi / 180)
+}
+
+func wrapAngle(a float64) float64 {
+	a = math.Mod(a+math.Pi, 2*math.Pi)
+	if a < 0 {
+		a += 2 * math.Pi
+	}
+	return a - math.Pi
+}
+
+func angleDiff(a, b float64) float64 {
+	return wrapAngle(a - b)
+}
+
+// toDevice turns a vector from the east, north, up frame into the device frame
+func toDevice(v [3]float64, pitch, roll, yaw float64) [3]float64 {
+	// Around z by -yaw
+	c, s := math.Cos(-yaw), math.Sin(-yaw)
+	v = [3]float64{v[0]*c - v[1]*s, v[0]*s + v[1]*c, v[2]}
+	// Around x by -pitch
+	c, s = math.Cos(-pitch), math.Sin(-pitch)
+	v = [3]float64{v[0], v[1]*c - v[2]*s, v[1]*s + v[2]*c}
+	// Around y by -roll
+	c, s = math.Cos(-roll), math.Sin(-roll)
+	return [3]float64{v[0]*c + v[2]*s, v[1], -v[0]*s + v[2]*c}
+}
@@ -0,0 +1,54 @@
+package client
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestHandheldSensors(t *testing.T) {
+	s := NewHandheldSensors(rand.NewSource(1))
+	now := time.Now()
+
+	prev := s.Sample(SensorState{Time: now, Latitude: 40, Course: 90, Speed: 1.4})
+	for i := 1; i <= 20; i++ {
+		info := s.Sample(SensorState{Time: now.Add(time.Duration(i) * time.Second), Latitude: 40, Course: 90, Speed: 1.4})
+
+		g := math.Sqrt(info.GravityX*info.GravityX + info.GravityY*info.GravityY + info.GravityZ*info.GravityZ)
+		if math.Abs(g-1) > 1e-9 {
+			t.Fatalf("Gravity not normalized %v", g)
+		}
+		if math.Abs(info.AttitudePitch-prev.AttitudePitch) > 0.2 {
+			t.Fatalf("Pitch jumped from %v to %v in a second", prev.AttitudePitch, info.AttitudePitch)
+		}
+		prev = info
+	}
+
+	// Walking east the yaw settles on -90 degrees
+	if math.Abs(prev.AttitudeYaw+math.Pi/2) > 0.3 {
+		t.Fatalf("Yaw %v does not follow the course", prev.AttitudeYaw)
+	}
+}
+
+func TestNewKeepsOptions(t *testing.T) {
+	opts := &Options{
+		AuthProvider: fakeAuth{"sensors"},
+		HashProvider: &fakeHash{},
+	}
+	a, err := New(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := New(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if opts.SensorModel != nil || opts.SignatureInfo.DeviceInfo != nil || opts.Clock != nil {
+		t.Fatal("New modified the caller options")
+	}
+	if a.options.SensorModel == b.options.SensorModel {
+		t.Fatal("Instances share their sensor model")
+	}
+}
@@ -0,0 +1,305 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/globalpokecache/POGOProtos-go"
+	"github.com/globalpokecache/pogobuf-go/clock"
+	"github.com/golang/protobuf/proto"
+)
+
+// fakeAuth logs in without a network
+type fakeAuth struct {
+	username string
+}
+
+func (a fakeAuth) Login(ctx context.Context) (string, error) { return "token-" + a.username, nil }
+func (a fakeAuth) Type() string                              { return "ptc" }
+func (a fakeAuth) GetUsername() string                       { return a.username }
+func (a fakeAuth) SetDebug(bool)                             {}
+
+// fakeHash hashes without a network, the hashes only depend on the inputs
+type fakeHash struct{}
+
+func (*fakeHash) AddKey(string) error    { return nil }
+func (*fakeHash) DelKey(string) error    { return nil }
+func (*fakeHash) GetKeys() []interface{} { return nil }
+func (*fakeHash) SetDebug(bool)          {}
+
+func (*fakeHash) Hash(authTicket, sessionData []byte, latitude, longitude, accuracy float64, timestamp uint64, requests [][]byte) (uint32, uint32, []uint64, error) {
+	hashes := make([]uint64, len(requests))
+	for i, r := range requests {
+		hashes[i] = uint64(len(r)) + timestamp
+	}
+	return uint32(timestamp), uint32(len(sessionData)), hashes, nil
+}
+
+// fakeServer answers the API in memory and records the request envelopes
+type fakeServer struct {
+	mu        sync.Mutex
+	envelopes []*protos.RequestEnvelope
+	tutorial  []protos.TutorialState
+	clock     clock.Clock
+}
+
+func (s *fakeServer) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		return nil, err
+	}
+	var envelope protos.RequestEnvelope
+	if err := proto.Unmarshal(body, &envelope); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	s.envelopes = append(s.envelopes, &envelope)
+	s.mu.Unlock()
+
+	response := &protos.ResponseEnvelope{
+		StatusCode: protos.ResponseEnvelope_OK,
+		RequestId:  envelope.RequestId,
+		ApiUrl:     "pgorelease.nianticlabs.com/custom",
+		AuthTicket: &protos.AuthTicket{Start: []byte("start"), End: []byte("end")},
+	}
+	for _, r := range envelope.Requests {
+		var reply proto.Message
+		switch r.RequestType {
+		case protos.RequestType_GET_PLAYER:
+			reply = &protos.GetPlayerResponse{Success: true, PlayerData: &protos.PlayerData{TutorialState: s.tutorial}}
+		case protos.RequestType_GET_INVENTORY:
+			reply = &protos.GetInventoryResponse{Success: true, InventoryDelta: &protos.InventoryDelta{NewTimestampMs: 1}}
+		case protos.RequestType_GET_MAP_OBJECTS:
+			var msg protos.GetMapObjectsMessage
+			if err := proto.Unmarshal(r.RequestMessage, &msg); err != nil {
+				return nil, err
+			}
+			resp := &protos.GetMapObjectsResponse{Status: protos.MapObjectsStatus_SUCCESS}
+			for _, id := range msg.CellId {
+				resp.MapCells = append(resp.MapCells, &protos.MapCell{
+					S2CellId:           id,
+					CurrentTimestampMs: int64(getTimestamp(s.clock.Now())),
+				})
+			}
+			reply = resp
+		}
+		var data []byte
+		if reply != nil {
+			if data, err = proto.Marshal(reply); err != nil {
+				return nil, err
+			}
+		}
+		response.Returns = append(response.Returns, data)
+	}
+
+	out, err := proto.Marshal(response)
+	if err != nil {
+		return nil, err
+	}
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{},
+		Body:       ioutil.NopCloser(bytes.NewReader(out)),
+		Request:    req,
+	}, nil
+}
+
+// requests returns the type of the first request of every envelope
+func (s *fakeServer) requests() []protos.RequestType {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var types []protos.RequestType
+	for _, e := range s.envelopes {
+		if len(e.Requests) > 0 {
+			types = append(types, e.Requests[0].RequestType)
+		}
+	}
+	return types
+}
+
+// fakeSession creates an instance talking to a fake server on an auto advancing
+// fake clock
+func fakeSession(t *testing.T, opts *Options) (*Instance, *fakeServer, *clock.Fake) {
+	start := time.Date(2017, 6, 1, 12, 0, 0, 0, time.UTC)
+	fake := clock.NewFake(start)
+	fake.SetAutoAdvance(true)
+
+	if opts.AuthProvider == nil {
+		opts.AuthProvider = fakeAuth{"session"}
+	}
+	if opts.Rand == nil {
+		opts.Rand = rand.NewSource(1)
+	}
+	opts.HashProvider = &fakeHash{}
+	opts.Clock = fake
+	c, err := New(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.SetPosition(context.Background(), 40.7829, -73.9654, 10, 30)
+
+	server := &fakeServer{clock: fake}
+	c.rpc.http.Transport = server
+	return c, server, fake
+}
+
+// initSession runs Init and fails the test if it does not return in time
+func initSession(t *testing.T, c *Instance) {
+	done := make(chan error, 1)
+	go func() {
+		_, _, err := c.Init(context.Background())
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("Init did not return")
+	}
+}
+
+func TestInitTutorialFakeClock(t *testing.T) {
+	c, server, fake := fakeSession(t, &Options{
+		SimulateApp:          true,
+		AutoCompleteTutorial: true,
+	})
+	start := fake.Now()
+	initSession(t, c)
+	defer c.cancel()
+
+	seen := map[protos.RequestType]bool{}
+	for _, r := range server.requests() {
+		seen[r] = true
+	}
+	for _, r := range []protos.RequestType{
+		protos.RequestType_GET_PLAYER,
+		protos.RequestType_DOWNLOAD_REMOTE_CONFIG_VERSION,
+		protos.RequestType_GET_ASSET_DIGEST,
+		protos.RequestType_MARK_TUTORIAL_COMPLETE,
+		protos.RequestType_SET_AVATAR,
+		protos.RequestType_ENCOUNTER_TUTORIAL_COMPLETE,
+		protos.RequestType_CLAIM_CODENAME,
+	} {
+		if !seen[r] {
+			t.Fatalf("Expected a %s request, got %v", r, server.requests())
+		}
+	}
+
+	// The tutorial pauses ran on the fake clock, and the location fixes kept
+	// up with it instead of stopping after the first one
+	elapsed := fake.Now().Sub(start)
+	if elapsed < 30*time.Second {
+		t.Fatalf("Expected the tutorial to take a while, took %s", elapsed)
+	}
+	if fix := time.Duration(c.lastLocationFix.TimestampSnapshot) * time.Millisecond; fix < elapsed/2 {
+		t.Fatalf("Last location fix at %s of a %s session", fix, elapsed)
+	}
+	for i, e := range server.envelopes {
+		if e.MsSinceLastLocationfix < 0 {
+			t.Fatalf("Envelope %d was sent before its last location fix", i)
+		}
+	}
+}
+
+func TestSeededSession(t *testing.T) {
+	var sessions [2]*fakeServer
+	for i := range sessions {
+		c, server, _ := fakeSession(t, &Options{
+			SimulateApp:       true,
+			Rand:              rand.NewSource(7),
+			SeededSessionHash: true,
+		})
+		initSession(t, c)
+		c.cancel()
+		sessions[i] = server
+	}
+
+	a, b := sessions[0].envelopes, sessions[1].envelopes
+	if len(a) != len(b) {
+		t.Fatalf("Sessions sent %d and %d envelopes", len(a), len(b))
+	}
+	for i := range a {
+		if !proto.Equal(a[i], b[i]) {
+			t.Fatalf("Envelope %d differs with the same seed", i)
+		}
+	}
+
+	// The session hash stays unpredictable unless asked otherwise
+	var hashes [2][]byte
+	for i := range hashes {
+		c, _, _ := fakeSession(t, &Options{Rand: rand.NewSource(7)})
+		initSession(t, c)
+		c.cancel()
+		hashes[i] = c.sessionHash
+	}
+	if bytes.Equal(hashes[0], hashes[1]) {
+		t.Fatal("Expected a random session hash with a seeded Rand")
+	}
+}
+
+// lastMapRequest returns the last GET_MAP_OBJECTS sent to the server
+func (s *fakeServer) lastMapRequest(t *testing.T) *protos.GetMapObjectsMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := len(s.envelopes) - 1; i >= 0; i-- {
+		for _, r := range s.envelopes[i].Requests {
+			if r.RequestType == protos.RequestType_GET_MAP_OBJECTS {
+				var msg protos.GetMapObjectsMessage
+				if err := proto.Unmarshal(r.RequestMessage, &msg); err != nil {
+					t.Fatal(err)
+				}
+				return &msg
+			}
+		}
+	}
+	t.Fatal("No GET_MAP_OBJECTS sent")
+	return nil
+}
+
+func TestMapBatches(t *testing.T) {
+	c, server, fake := fakeSession(t, &Options{})
+	initSession(t, c)
+	defer c.cancel()
+
+	ctx := context.Background()
+	batches := [][]uint64{{1, 2}, {3, 4}}
+	for pass := 0; pass < 2; pass++ {
+		for _, batch := range batches {
+			resp, err := c.GetMapObjects(ctx, batch, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.Status != protos.MapObjectsStatus_SUCCESS || len(resp.MapCells) != 2 {
+				t.Fatalf("Expected the server response, got %v", resp)
+			}
+			for _, since := range server.lastMapRequest(t).SinceTimestampMs {
+				if pass == 0 && since != 0 || pass == 1 && since == 0 {
+					t.Fatalf("Pass %d of %v requested since %d", pass, batch, since)
+				}
+			}
+		}
+	}
+	if cells := c.MapCells(); len(cells) != 2 || cells[0].S2CellId != 3 {
+		t.Fatalf("Expected the cells of the last batch, got %v", cells)
+	}
+
+	// Cells not scanned for a while are requested in full again
+	fake.Advance(mapCellMaxAge + time.Minute)
+	if _, err := c.GetMapObjects(ctx, batches[0], nil); err != nil {
+		t.Fatal(err)
+	}
+	for _, since := range server.lastMapRequest(t).SinceTimestampMs {
+		if since != 0 {
+			t.Fatalf("Old cell requested since %d", since)
+		}
+	}
+}
@@ -0,0 +1,222 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"time"
+)
+
+// Pause is a wait of the simulated app, the time a player spends on a screen
+// or the app takes between two requests
+type Pause string
+
+const (
+	PauseAppStart                Pause = "app_start"
+	PauseAfterGetPlayer          Pause = "after_get_player"
+	PauseAfterAssetDigest        Pause = "after_asset_digest"
+	PauseAfterPlayerProfile      Pause = "after_player_profile"
+	PauseAfterLevelUpRewards     Pause = "after_level_up_rewards"
+	PauseAfterRegisterBackground Pause = "after_register_background"
+	PauseAfterInit               Pause = "after_init"
+
+	PauseLegalScreen         Pause = "legal_screen"
+	PauseAfterLegalScreen    Pause = "after_legal_screen"
+	PauseAvatarScreen        Pause = "avatar_screen"
+	PauseAvatarSelection     Pause = "avatar_selection"
+	PauseAvatarConfirm       Pause = "avatar_confirm"
+	PauseAfterAvatar         Pause = "after_avatar"
+	PauseCaptureScreen       Pause = "capture_screen"
+	PauseCaptureEncounter    Pause = "capture_encounter"
+	PauseAfterCapture        Pause = "after_capture"
+	PauseNameSelection       Pause = "name_selection"
+	PauseAfterNameClaim      Pause = "after_name_claim"
+	PauseNameConfirm         Pause = "name_confirm"
+	PauseFirstTimeExperience Pause = "first_time_experience"
+	PauseAfterTutorial       Pause = "after_tutorial"
+)
+
+// Delay is a uniformly distributed wait between Min and Max
+type Delay struct {
+	Min time.Duration
+	Max time.Duration
+}
+
+func ms(min, max int64) Delay {
+	return Delay{time.Duration(min) * time.Millisecond, time.Duration(max) * time.Millisecond}
+}
+
+type delayJSON struct {
+	Min string `json:"min"`
+	Max string `json:"max"`
+}
+
+// MarshalJSON writes the delay as {"min": "430ms", "max": "970ms"}
+func (d Delay) MarshalJSON() ([]byte, error) {
+	return json.Marshal(delayJSON{d.Min.String(), d.Max.String()})
+}
+
+func (d *Delay) UnmarshalJSON(data []byte) error {
+	var v delayJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	min, err := time.ParseDuration(v.Min)
+	if err != nil {
+		return err
+	}
+	max, err := time.ParseDuration(v.Max)
+	if err != nil {
+		return err
+	}
+	if min < 0 || max < min {
+		return fmt.Errorf("Invalid delay %s-%s", v.Min, v.Max)
+	}
+
+	d.Min, d.Max = min, max
+	return nil
+}
+
+// TimingProfile maps the app pauses to their delays. Pauses missing from
+// Delays wait as in TimingRealistic.
+type TimingProfile struct {
+	Name   string          `json:"name"`
+	Delays map[Pause]Delay `json:"delays"`
+}
+
+var (
+	// TimingRealistic waits like a player going through the app
+	TimingRealistic = TimingProfile{
+		Name: "realistic",
+		Delays: map[Pause]Delay{
+			PauseAppStart:                ms(430, 970),
+			PauseAfterGetPlayer:          ms(530, 1000),
+			PauseAfterAssetDigest:        ms(870, 2000),
+			PauseAfterPlayerProfile:      ms(200, 400),
+			PauseAfterLevelUpRewards:     ms(450, 700),
+			PauseAfterRegisterBackground: ms(500, 1300),
+			PauseAfterInit:               ms(500, 800),
+
+			PauseLegalScreen:         ms(350, 525),
+			PauseAfterLegalScreen:    ms(1000, 1100),
+			PauseAvatarScreen:        ms(5000, 5100),
+			PauseAvatarSelection:     ms(7000, 14000),
+			PauseAvatarConfirm:       ms(500, 4000),
+			PauseAfterAvatar:         ms(500, 1000),
+			PauseCaptureScreen:       ms(700, 900),
+			PauseCaptureEncounter:    ms(7000, 10300),
+			PauseAfterCapture:        ms(400, 500),
+			PauseNameSelection:       ms(12000, 18000),
+			PauseAfterNameClaim:      ms(700, 800),
+			PauseNameConfirm:         ms(130, 200),
+			PauseFirstTimeExperience: ms(3900, 4500),
+			PauseAfterTutorial:       ms(200, 300),
+		},
+	}
+
+	// TimingFast keeps a tenth of the realistic delays for bulk jobs
+	TimingFast = TimingRealistic.Scaled("fast", 0.1)
+
+	// TimingCautious waits half again as long as the realistic profile
+	TimingCautious = TimingRealistic.Scaled("cautious", 1.5)
+
+	timingProfiles = map[string]TimingProfile{
+		TimingRealistic.Name: TimingRealistic,
+		TimingFast.Name:      TimingFast,
+		TimingCautious.Name:  TimingCautious,
+	}
+)
+
+// Scaled returns a copy of the profile with every delay multiplied by factor
+func (p TimingProfile) Scaled(name string, factor float64) TimingProfile {
+	scaled := TimingProfile{Name: name, Delays: map[Pause]Delay{}}
+	for pause, d := range p.Delays {
+		scaled.Delays[pause] = Delay{
+			Min: time.Duration(float64(d.Min) * factor),
+			Max: time.Duration(float64(d.Max) * factor),
+		}
+	}
+	return scaled
+}
+
+// Delay returns the delay of a pause
+func (p TimingProfile) Delay(pause Pause) Delay {
+	if d, ok := p.Delays[pause]; ok {
+		return d
+	}
+	return TimingRealistic.Delays[pause]
+}
+
+// GetTimingProfile returns a built-in profile by name
+func GetTimingProfile(name string) (TimingProfile, error) {
+	p, ok := timingProfiles[name]
+	if !ok {
+		return TimingProfile{}, fmt.Errorf("Unknown timing profile %q", name)
+	}
+	return p, nil
+}
+
+// timingConfig is the file format of LoadTimingProfile
+type timingConfig struct {
+	Name   string          `json:"name"`
+	Base   string          `json:"base"`
+	Scale  float64         `json:"scale"`
+	Delays map[Pause]Delay `json:"delays"`
+}
+
+// LoadTimingProfile reads a profile from a JSON file like
+//
+//	{
+//		"name": "overnight",
+//		"base": "realistic",
+//		"scale": 0.5,
+//		"delays": {"name_selection": {"min": "20s", "max": "40s"}}
+//	}
+//
+// The base profile, realistic by default, is scaled and then the listed
+// delays replace its own. Unknown delay names are an error.
+func LoadTimingProfile(path string) (TimingProfile, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return TimingProfile{}, err
+	}
+
+	var config timingConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return TimingProfile{}, fmt.Errorf("%s: %s", path, err)
+	}
+
+	if config.Base == "" {
+		config.Base = TimingRealistic.Name
+	}
+	base, err := GetTimingProfile(config.Base)
+	if err != nil {
+		return TimingProfile{}, fmt.Errorf("%s: %s", path, err)
+	}
+	if config.Scale == 0 {
+		config.Scale = 1
+	}
+	if config.Name == "" {
+		config.Name = "custom"
+	}
+
+	profile := base.Scaled(config.Name, config.Scale)
+	for pause, d := range config.Delays {
+		if _, ok := TimingRealistic.Delays[pause]; !ok {
+			return TimingProfile{}, fmt.Errorf("%s: unknown delay %q", path, pause)
+		}
+		profile.Delays[pause] = d
+	}
+	return profile, nil
+}
+
+// pause waits for a delay of the timing profile
+func (c *Instance) pause(p Pause) {
+	d := c.options.Timing.Delay(p)
+	wait := d.Min
+	if d.Max > d.Min {
+		wait += time.Duration(c.rand.Float64() * float64(d.Max-d.Min))
+	}
+	c.clock.Sleep(wait)
+}
@@ -0,0 +1,57 @@
+package client
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestLoadTimingProfile(t *testing.T) {
+	f, err := ioutil.TempFile("", "timing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+
+	f.WriteString(`{
+		"name": "overnight",
+		"base": "cautious",
+		"scale": 2,
+		"delays": {"name_selection": {"min": "20s", "max": "40s"}}
+	}`)
+	f.Close()
+
+	p, err := LoadTimingProfile(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Name != "overnight" {
+		t.Fatalf("Unexpected name %q", p.Name)
+	}
+	if d := p.Delay(PauseNameSelection); d.Min != 20*time.Second || d.Max != 40*time.Second {
+		t.Fatalf("Override not applied %+v", d)
+	}
+	if d := p.Delay(PauseAfterTutorial); d.Min != 600*time.Millisecond || d.Max != 900*time.Millisecond {
+		t.Fatalf("Base not scaled %+v", d)
+	}
+
+	if d := (TimingProfile{}).Delay(PauseAppStart); d != TimingRealistic.Delays[PauseAppStart] {
+		t.Fatal("Missing delays must fall back to the realistic profile")
+	}
+}
+
+func TestLoadTimingProfileUnknownPause(t *testing.T) {
+	f, err := ioutil.TempFile("", "timing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+
+	f.WriteString(`{"delays": {"name_selecton": {"min": "20s", "max": "40s"}}}`)
+	f.Close()
+
+	if _, err := LoadTimingProfile(f.Name()); err == nil {
+		t.Fatal("Expected a misspelled delay to be rejected")
+	}
+}
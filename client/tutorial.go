@@ -45,9 +45,9 @@ func (c *Instance) completeTutorial(ctx context.Context, tutorialState []protos.
 			return false, err
 		}
 
-		randSleep(350, 525)
+		c.pause(PauseLegalScreen)
 
-		getPlayerRequest, err := c.GetPlayerRequest("US", "en", "America/Chicago")
+		getPlayerRequest, err := c.playerRequest()
 		if err != nil {
 			return false, err
 		}
@@ -55,11 +55,11 @@ func (c *Instance) completeTutorial(ctx context.Context, tutorialState []protos.
 		if err != nil {
 			return false, err
 		}
-		randSleep(1000, 1100)
+		c.pause(PauseAfterLegalScreen)
 	}
 
 	if _, ok := tuto[protos.TutorialState_AVATAR_SELECTION]; !ok {
-		randSleep(5000, 5100)
+		c.pause(PauseAvatarScreen)
 		listAvatar, err := c.ListAvatarCustomizationsRequest(0, []protos.Slot{}, []protos.Filter{2})
 		if err != nil {
 			return false, err
@@ -71,17 +71,17 @@ func (c *Instance) completeTutorial(ctx context.Context, tutorialState []protos.
 			return false, err
 		}
 
-		randSleep(7000, 14000)
+		c.pause(PauseAvatarSelection)
 		setAvatar, err := c.SetAvatarRequest(
-			randInt(3),
-			randInt(5),
-			randInt(3),
-			randInt(2),
-			randInt(4),
-			randInt(6),
+			c.rand.Intn(3),
+			c.rand.Intn(5),
+			c.rand.Intn(3),
+			c.rand.Intn(2),
+			c.rand.Intn(4),
+			c.rand.Intn(6),
 			0,
-			randInt(4),
-			randInt(5),
+			c.rand.Intn(4),
+			c.rand.Intn(5),
 		)
 		if err != nil {
 			return false, err
@@ -93,7 +93,7 @@ func (c *Instance) completeTutorial(ctx context.Context, tutorialState []protos.
 			return false, err
 		}
 
-		randSleep(500, 4000)
+		c.pause(PauseAvatarConfirm)
 
 		markComplete, err := c.MarkTutorialCompleteRequest([]protos.TutorialState{protos.TutorialState_AVATAR_SELECTION}, false, false)
 		if err != nil {
@@ -104,11 +104,11 @@ func (c *Instance) completeTutorial(ctx context.Context, tutorialState []protos.
 			return false, err
 		}
 
-		randSleep(500, 1000)
+		c.pause(PauseAfterAvatar)
 	}
 
 	if _, ok := tuto[protos.TutorialState_POKEMON_CAPTURE]; !ok {
-		randSleep(700, 900)
+		c.pause(PauseCaptureScreen)
 
 		fmt.Println(assets)
 		getDownloadsURLs, err := c.GetDownloadURLsRequest(assets)
@@ -123,9 +123,9 @@ func (c *Instance) completeTutorial(ctx context.Context, tutorialState []protos.
 			return false, err
 		}
 
-		randSleep(7000, 10300)
+		c.pause(PauseCaptureEncounter)
 
-		crea := []int32{1, 4, 7}[randInt(3)]
+		crea := []int32{1, 4, 7}[c.rand.Intn(3)]
 
 		encounterRequest, err := c.EncounterTutorialCompleteRequest(crea)
 		if err != nil {
@@ -139,9 +139,9 @@ func (c *Instance) completeTutorial(ctx context.Context, tutorialState []protos.
 			return false, err
 		}
 
-		randSleep(400, 500)
+		c.pause(PauseAfterCapture)
 
-		getPlayerRequest, err := c.GetPlayerRequest("US", "en", "America/Chicago")
+		getPlayerRequest, err := c.playerRequest()
 		if err != nil {
 			return false, err
 		}
@@ -155,7 +155,7 @@ func (c *Instance) completeTutorial(ctx context.Context, tutorialState []protos.
 	}
 
 	if _, ok := tuto[protos.TutorialState_NAME_SELECTION]; !ok {
-		randSleep(12000, 18000)
+		c.pause(PauseNameSelection)
 
 		claimCodename, err := c.ClaimCodenameRequest(account)
 		if err != nil {
@@ -169,9 +169,9 @@ func (c *Instance) completeTutorial(ctx context.Context, tutorialState []protos.
 			return false, err
 		}
 
-		randSleep(700, 800)
+		c.pause(PauseAfterNameClaim)
 
-		getPlayerRequest, err := c.GetPlayerRequest("US", "en", "America/Chicago")
+		getPlayerRequest, err := c.playerRequest()
 		if err != nil {
 			return false, err
 		}
@@ -183,7 +183,7 @@ func (c *Instance) completeTutorial(ctx context.Context, tutorialState []protos.
 			return false, err
 		}
 
-		randSleep(130, 200)
+		c.pause(PauseNameConfirm)
 
 		markComplete, err := c.MarkTutorialCompleteRequest([]protos.TutorialState{protos.TutorialState_NAME_SELECTION}, false, false)
 		if err != nil {
@@ -200,7 +200,7 @@ func (c *Instance) completeTutorial(ctx context.Context, tutorialState []protos.
 	}
 
 	if _, ok := tuto[protos.TutorialState_FIRST_TIME_EXPERIENCE_COMPLETE]; !ok {
-		randSleep(3900, 4500)
+		c.pause(PauseFirstTimeExperience)
 
 		markComplete, err := c.MarkTutorialCompleteRequest([]protos.TutorialState{protos.TutorialState_FIRST_TIME_EXPERIENCE_COMPLETE}, false, false)
 		if err != nil {
@@ -223,7 +223,7 @@ func (c *Instance) completeTutorial(ctx context.Context, tutorialState []protos.
 	//     await self.random_sleep(.8, 1.2)
 	//    }
 
-	randSleep(200, 300)
+	c.pause(PauseAfterTutorial)
 
 	return false, nil
 }
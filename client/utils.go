@@ -1,48 +1,9 @@
 package client
 
 import (
-	"math"
-	"math/rand"
 	"time"
-
-	"github.com/globalpokecache/POGOProtos-go"
-	"github.com/globalpokecache/pogobuf-go/auth"
-	"github.com/satori/go.uuid"
-	"strings"
 )
 
-func randSleep(low, high int64) {
-	time.Sleep(time.Duration(low+int64(randInt(int(high-low)))) * time.Millisecond)
-}
-
-func randTriang(lower, upper, mode float64) float64 {
-	var c = (mode - lower) / (upper - lower)
-	var u = randFloat()
-
-	if u <= c {
-		return lower + math.Sqrt(u*(upper-lower)*(mode-lower))
-	}
-
-	return upper - math.Sqrt((1-u)*(upper-lower)*(upper-mode))
-}
-func randInt(l int) int {
-	var s1 = rand.NewSource(time.Now().UnixNano())
-	var r1 = rand.New(s1)
-	return r1.Intn(l)
-}
-
-func randInt64(l int64) int64 {
-	var s1 = rand.NewSource(time.Now().UnixNano())
-	var r1 = rand.New(s1)
-	return r1.Int63n(l)
-}
-
-func randFloat() float64 {
-	var s1 = rand.NewSource(time.Now().UnixNano())
-	var r1 = rand.New(s1)
-	return r1.Float64()
-}
-
 func getTimestamp(t time.Time) uint64 {
 	return uint64(t.UnixNano() / int64(time.Millisecond))
 }
@@ -80,21 +41,3 @@ var (
 		"10.0", "10.0.1", "10.0.2", "10.0.3", "10.1", "10.1.1",
 	}
 )
-
-func NewDevice(p auth.Provider) *protos.Signature_DeviceInfo {
-	uuid := uuid.NewV5(uuid.Nil, p.GetUsername())
-	deviceID := strings.Replace(uuid.String(), "-", "", -1)
-	device := Devices[randInt(len(Devices))]
-	firmware := OsVersions[randInt(len(OsVersions))]
-
-	return &protos.Signature_DeviceInfo{
-		DeviceId:             deviceID,
-		DeviceBrand:          "Apple",
-		DeviceModel:          "iPhone",
-		FirmwareBrand:        "iPhone OS",
-		HardwareManufacturer: "Apple",
-		DeviceModelBoot:      device[0],
-		HardwareModel:        device[2],
-		FirmwareType:         firmware,
-	}
-}
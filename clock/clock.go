@@ -0,0 +1,156 @@
+package clock
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Clock is the source of time of the client, so tests can run the delays of a
+// session instantly
+type Clock interface {
+	Now() time.Time
+	Sleep(d time.Duration)
+	After(d time.Duration) <-chan time.Time
+	// AfterFunc calls f in its own goroutine after d, stop cancels the call
+	// if it has not started yet
+	AfterFunc(d time.Duration, f func()) (stop func() bool)
+}
+
+// Real is the system clock
+type Real struct{}
+
+func (Real) Now() time.Time                         { return time.Now() }
+func (Real) Sleep(d time.Duration)                  { time.Sleep(d) }
+func (Real) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+func (Real) AfterFunc(d time.Duration, f func()) func() bool {
+	return time.AfterFunc(d, f).Stop
+}
+
+type waiter struct {
+	at time.Time
+	c  chan time.Time
+	f  func()
+}
+
+// Fake is a clock that only moves when told to. Sleep and After wait for
+// Advance, unless auto advance is on, then they move the clock forward by
+// the duration and return right away. AfterFunc never moves the clock, its
+// functions run synchronously in time order when the clock passes them, so
+// background loops follow the pace of the code that advances the clock.
+type Fake struct {
+	mu          sync.Mutex
+	now         time.Time
+	autoAdvance bool
+	waiters     []*waiter
+}
+
+func NewFake(now time.Time) *Fake {
+	return &Fake{now: now}
+}
+
+// SetAutoAdvance makes Sleep and After move the clock instead of waiting
+func (f *Fake) SetAutoAdvance(auto bool) {
+	f.mu.Lock()
+	f.autoAdvance = auto
+	f.mu.Unlock()
+}
+
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+func (f *Fake) Sleep(d time.Duration) {
+	<-f.After(d)
+}
+
+func (f *Fake) After(d time.Duration) <-chan time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	c := make(chan time.Time, 1)
+	if f.autoAdvance && d > 0 {
+		f.advance(d)
+		d = 0
+	}
+	if d <= 0 {
+		c <- f.now
+		return c
+	}
+
+	f.add(&waiter{at: f.now.Add(d), c: c})
+	return c
+}
+
+func (f *Fake) AfterFunc(d time.Duration, fn func()) func() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if d < 0 {
+		d = 0
+	}
+	w := &waiter{at: f.now.Add(d), f: fn}
+	f.add(w)
+	return func() bool {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		for i, o := range f.waiters {
+			if o == w {
+				f.waiters = append(f.waiters[:i], f.waiters[i+1:]...)
+				return true
+			}
+		}
+		return false
+	}
+}
+
+func (f *Fake) add(w *waiter) {
+	f.waiters = append(f.waiters, w)
+	sort.SliceStable(f.waiters, func(i, j int) bool {
+		return f.waiters[i].at.Before(f.waiters[j].at)
+	})
+}
+
+// Advance moves the clock forward, waking the sleepers whose time has come
+func (f *Fake) Advance(d time.Duration) {
+	f.mu.Lock()
+	f.advance(d)
+	f.mu.Unlock()
+}
+
+// advance is called with the lock held, it releases it while running the
+// functions of AfterFunc so they can use the clock
+func (f *Fake) advance(d time.Duration) {
+	target := f.now.Add(d)
+
+	for len(f.waiters) > 0 && !f.waiters[0].at.After(target) {
+		w := f.waiters[0]
+		f.waiters = f.waiters[1:]
+		if w.at.After(f.now) {
+			f.now = w.at
+		}
+		if w.f == nil {
+			w.c <- f.now
+			continue
+		}
+		f.mu.Unlock()
+		w.f()
+		f.mu.Lock()
+	}
+
+	// A function may have moved the clock past the target already
+	if target.After(f.now) {
+		f.now = target
+	}
+}
+
+// Waiters returns how many Sleep, After and AfterFunc calls wait for the clock, so tests
+// know when to advance it
+func (f *Fake) Waiters() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.waiters)
+}
@@ -0,0 +1,70 @@
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFake(t *testing.T) {
+	start := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+	f := NewFake(start)
+
+	c := f.After(time.Second)
+	f.Advance(500 * time.Millisecond)
+	select {
+	case <-c:
+		t.Fatal("Woke up too early")
+	default:
+	}
+
+	f.Advance(500 * time.Millisecond)
+	select {
+	case now := <-c:
+		if !now.Equal(start.Add(time.Second)) {
+			t.Fatalf("Unexpected wake up time %s", now)
+		}
+	default:
+		t.Fatal("Did not wake up")
+	}
+
+	f.SetAutoAdvance(true)
+	f.Sleep(time.Minute)
+	if got := f.Now().Sub(start); got != time.Minute+time.Second {
+		t.Fatalf("Expected the clock to move by the sleep, moved %s", got)
+	}
+}
+
+func TestFakeAfterFunc(t *testing.T) {
+	start := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+	f := NewFake(start)
+	f.SetAutoAdvance(true)
+
+	// A self rescheduling loop must follow the sleeps, not run ahead of them
+	var ticks []time.Duration
+	var tick func()
+	tick = func() {
+		ticks = append(ticks, f.Now().Sub(start))
+		f.AfterFunc(time.Second, tick)
+	}
+	f.AfterFunc(time.Second, tick)
+
+	stop := f.AfterFunc(1500*time.Millisecond, func() {
+		t.Fatal("Stopped function was called")
+	})
+	if !stop() {
+		t.Fatal("Expected to stop the pending function")
+	}
+
+	f.Sleep(3500 * time.Millisecond)
+	if len(ticks) != 3 {
+		t.Fatalf("Expected 3 ticks, got %v", ticks)
+	}
+	for i, d := range ticks {
+		if d != time.Duration(i+1)*time.Second {
+			t.Fatalf("Tick %d ran at %s", i, d)
+		}
+	}
+	if got := f.Now().Sub(start); got != 3500*time.Millisecond {
+		t.Fatalf("Expected the clock at the end of the sleep, got %s", got)
+	}
+}
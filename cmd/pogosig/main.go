@@ -0,0 +1,255 @@
+// Command pogosig decrypts and prints the signature of recorded requests.
+//
+// It reads a serialized RequestEnvelope, raw or base64, or a session file
+// holding one base64 envelope per line, from a file or stdin:
+//
+//	pogosig -version 5500 envelope.bin
+//	pogosig -format session session.txt
+//
+// Every signature is printed as JSON along with the inconsistencies found in
+// it, like location fixes out of order or request hashes not matching the
+// requests.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/globalpokecache/POGOProtos-go"
+	"github.com/globalpokecache/pogobuf-go/pcrypt"
+	"github.com/globalpokecache/pogobuf-go/versions"
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+)
+
+var errNoSignature = errors.New("No SEND_ENCRYPTED_SIGNATURE platform request")
+
+type result struct {
+	Envelope  int             `json:"envelope"`
+	RequestID uint64          `json:"request_id"`
+	Requests  []string        `json:"requests"`
+	Signature json.RawMessage `json:"signature,omitempty"`
+	Warnings  []string        `json:"warnings,omitempty"`
+	Error     string          `json:"error,omitempty"`
+}
+
+func main() {
+	format := flag.String("format", "auto", "input format: auto, raw, base64 or session")
+	version := flag.Int("version", versions.Default, "API version the requests were made with")
+	flag.Parse()
+
+	profile, err := versions.Get(*version)
+	if err != nil {
+		log.Fatalf("Version %d: %s", *version, err)
+	}
+	cipher, err := pcrypt.ForVersion(profile.Version, profile.Encryption.Key, profile.Encryption.IntegrityByte)
+	if err != nil {
+		log.Fatalf("Version %d: %s", *version, err)
+	}
+
+	var data []byte
+	if flag.NArg() > 0 {
+		data, err = ioutil.ReadFile(flag.Arg(0))
+	} else {
+		data, err = ioutil.ReadAll(os.Stdin)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	envelopes, err := readEnvelopes(data, *format)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	marshaler := &jsonpb.Marshaler{Indent: "\t", OrigName: true}
+	var previous *protos.Signature
+	var results []result
+	for i, envelope := range envelopes {
+		r := result{Envelope: i, RequestID: envelope.RequestId}
+		for _, req := range envelope.Requests {
+			r.Requests = append(r.Requests, req.RequestType.String())
+		}
+
+		signature, err := decryptSignature(cipher, envelope)
+		if err != nil {
+			r.Error = err.Error()
+			results = append(results, r)
+			continue
+		}
+
+		s, err := marshaler.MarshalToString(signature)
+		if err != nil {
+			log.Fatalf("Failed to marshal the signature: %s", err)
+		}
+		r.Signature = json.RawMessage(s)
+		r.Warnings = check(envelope, signature, previous, profile)
+		previous = signature
+
+		results = append(results, r)
+	}
+
+	out, _ := json.MarshalIndent(results, "", "\t")
+	fmt.Println(string(out))
+}
+
+// readEnvelopes parses the input, auto tries a session, base64 then raw
+func readEnvelopes(data []byte, format string) ([]*protos.RequestEnvelope, error) {
+	switch format {
+	case "raw":
+		e, err := parseEnvelope(data)
+		if err != nil {
+			return nil, err
+		}
+		return []*protos.RequestEnvelope{e}, nil
+	case "base64":
+		raw, err := decodeBase64(string(data))
+		if err != nil {
+			return nil, err
+		}
+		return readEnvelopes(raw, "raw")
+	case "session":
+		var envelopes []*protos.RequestEnvelope
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		scanner.Buffer(nil, 16*1024*1024)
+		for n := 1; scanner.Scan(); n++ {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			e, err := readEnvelopes([]byte(line), "base64")
+			if err != nil {
+				return nil, fmt.Errorf("Line %d: %s", n, err)
+			}
+			envelopes = append(envelopes, e...)
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+		if len(envelopes) == 0 {
+			return nil, errors.New("Session has no envelope")
+		}
+		return envelopes, nil
+	case "auto":
+		for _, f := range []string{"session", "base64", "raw"} {
+			if envelopes, err := readEnvelopes(data, f); err == nil {
+				return envelopes, nil
+			}
+		}
+		return nil, errors.New("Input is not a request envelope")
+	default:
+		return nil, fmt.Errorf("Unknown format %q", format)
+	}
+}
+
+func decodeBase64(s string) ([]byte, error) {
+	s = strings.TrimSpace(s)
+	for _, enc := range []*base64.Encoding{base64.StdEncoding, base64.URLEncoding, base64.RawStdEncoding, base64.RawURLEncoding} {
+		if data, err := enc.DecodeString(s); err == nil {
+			return data, nil
+		}
+	}
+	return nil, errors.New("Invalid base64")
+}
+
+func parseEnvelope(data []byte) (*protos.RequestEnvelope, error) {
+	var envelope protos.RequestEnvelope
+	if err := proto.Unmarshal(data, &envelope); err != nil {
+		return nil, err
+	}
+	if len(envelope.Requests) == 0 && len(envelope.PlatformRequests) == 0 {
+		return nil, errors.New("Empty request envelope")
+	}
+	return &envelope, nil
+}
+
+func decryptSignature(cipher pcrypt.Cipher, envelope *protos.RequestEnvelope) (*protos.Signature, error) {
+	for _, pr := range envelope.PlatformRequests {
+		if pr.Type != protos.PlatformRequestType_SEND_ENCRYPTED_SIGNATURE {
+			continue
+		}
+
+		var req protos.SendEncryptedSignatureRequest
+		if err := proto.Unmarshal(pr.RequestMessage, &req); err != nil {
+			return nil, fmt.Errorf("Failed to unmarshal SEND_ENCRYPTED_SIGNATURE: %s", err)
+		}
+		decrypted, err := cipher.Decrypt(req.EncryptedSignature)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to decrypt the signature: %s", err)
+		}
+		var signature protos.Signature
+		if err := proto.Unmarshal(decrypted, &signature); err != nil {
+			return nil, fmt.Errorf("Failed to unmarshal the signature: %s", err)
+		}
+		return &signature, nil
+	}
+	return nil, errNoSignature
+}
+
+// Difference in ms tolerated between the envelope and signature timings
+const timingTolerance = 5
+
+// check lists what a server could find inconsistent in a signature
+func check(envelope *protos.RequestEnvelope, s, previous *protos.Signature, profile versions.Profile) []string {
+	var warnings []string
+	warn := func(format string, a ...interface{}) {
+		warnings = append(warnings, fmt.Sprintf(format, a...))
+	}
+
+	if len(envelope.Requests) > 0 && len(s.RequestHash) != len(envelope.Requests) {
+		warn("%d request hashes for %d requests", len(s.RequestHash), len(envelope.Requests))
+	}
+	if s.Unknown25 != profile.Unknown25 {
+		warn("unk25 %d does not match %d of version %s", s.Unknown25, profile.Unknown25, profile.Name())
+	}
+
+	if s.Timestamp < s.TimestampSinceStart {
+		warn("timestamp_since_start %d is after the timestamp %d", s.TimestampSinceStart, s.Timestamp)
+	}
+	if previous != nil {
+		if s.Timestamp < previous.Timestamp {
+			warn("timestamp %d is before the previous signature %d", s.Timestamp, previous.Timestamp)
+		}
+		if s.TimestampSinceStart < previous.TimestampSinceStart {
+			warn("timestamp_since_start %d is before the previous signature %d", s.TimestampSinceStart, previous.TimestampSinceStart)
+		}
+	}
+
+	for i, fix := range s.LocationFix {
+		if fix.TimestampSnapshot > s.TimestampSinceStart {
+			warn("location fix %d is after the signature", i)
+		}
+		if i > 0 && fix.TimestampSnapshot < s.LocationFix[i-1].TimestampSnapshot {
+			warn("location fix %d is before location fix %d", i, i-1)
+		}
+	}
+	if n := len(s.LocationFix); n > 0 {
+		since := int64(s.TimestampSinceStart) - int64(s.LocationFix[n-1].TimestampSnapshot)
+		if d := since - envelope.MsSinceLastLocationfix; d > timingTolerance || d < -timingTolerance {
+			warn("ms_since_last_locationfix %d does not match the last location fix, %d ms before the signature", envelope.MsSinceLastLocationfix, since)
+		}
+	} else if envelope.MsSinceLastLocationfix != -1 {
+		warn("ms_since_last_locationfix is %d without location fix", envelope.MsSinceLastLocationfix)
+	}
+
+	for i, sensor := range s.SensorInfo {
+		if sensor.TimestampSnapshot > s.TimestampSinceStart {
+			warn("sensor info %d is after the signature", i)
+		}
+	}
+
+	if s.DeviceInfo == nil {
+		warn("no device info")
+	}
+
+	return warnings
+}
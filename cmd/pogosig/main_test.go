@@ -0,0 +1,179 @@
+package main
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/globalpokecache/POGOProtos-go"
+	"github.com/globalpokecache/pogobuf-go/pcrypt"
+	"github.com/globalpokecache/pogobuf-go/versions"
+	"github.com/golang/protobuf/proto"
+)
+
+func testProfile(t *testing.T) versions.Profile {
+	profile, err := versions.Get(versions.Default)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return profile
+}
+
+// testSignature is consistent with the envelope of testEnvelope
+func testSignature(profile versions.Profile) *protos.Signature {
+	return &protos.Signature{
+		Timestamp:           1500000010000,
+		TimestampSinceStart: 10000,
+		Unknown25:           profile.Unknown25,
+		RequestHash:         []uint64{1},
+		LocationFix: []*protos.Signature_LocationFix{
+			{TimestampSnapshot: 8000},
+			{TimestampSnapshot: 9000},
+		},
+		SensorInfo: []*protos.Signature_SensorInfo{{TimestampSnapshot: 9500}},
+		DeviceInfo: &protos.Signature_DeviceInfo{DeviceId: "device"},
+	}
+}
+
+// testEnvelope returns an envelope carrying the encrypted signature and its
+// serialized form
+func testEnvelope(t *testing.T, s *protos.Signature) (*protos.RequestEnvelope, []byte) {
+	plain, err := proto.Marshal(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, err := proto.Marshal(&protos.SendEncryptedSignatureRequest{
+		EncryptedSignature: pcrypt.Encrypt(plain, uint32(s.TimestampSinceStart)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	envelope := &protos.RequestEnvelope{
+		StatusCode:             2,
+		RequestId:              42,
+		MsSinceLastLocationfix: 1000,
+		Requests:               []*protos.Request{{RequestType: protos.RequestType_GET_PLAYER}},
+		PlatformRequests: []*protos.RequestEnvelope_PlatformRequest{{
+			Type:           protos.PlatformRequestType_SEND_ENCRYPTED_SIGNATURE,
+			RequestMessage: msg,
+		}},
+	}
+	data, err := proto.Marshal(envelope)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return envelope, data
+}
+
+func TestReadEnvelopes(t *testing.T) {
+	profile := testProfile(t)
+	_, raw := testEnvelope(t, testSignature(profile))
+	b64 := base64.StdEncoding.EncodeToString(raw)
+	session := "# recorded session\n" + b64 + "\n\n" + base64.RawURLEncoding.EncodeToString(raw) + "\n"
+
+	tests := []struct {
+		name   string
+		data   string
+		format string
+		count  int
+	}{
+		{"raw", string(raw), "raw", 1},
+		{"base64", b64 + "\n", "base64", 1},
+		{"session", session, "session", 2},
+		{"auto raw", string(raw), "auto", 1},
+		{"auto base64", b64, "auto", 1},
+		{"auto session", session, "auto", 2},
+		{"empty session", "# nothing\n", "session", 0},
+		{"auto garbage", "not an envelope", "auto", 0},
+		{"unknown format", b64, "json", 0},
+	}
+
+	cipher, err := pcrypt.ForVersion(profile.Version, profile.Encryption.Key, profile.Encryption.IntegrityByte)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range tests {
+		envelopes, err := readEnvelopes([]byte(test.data), test.format)
+		if test.count == 0 {
+			if err == nil {
+				t.Fatalf("%s: expected an error", test.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: %s", test.name, err)
+		}
+		if len(envelopes) != test.count {
+			t.Fatalf("%s: expected %d envelopes, got %d", test.name, test.count, len(envelopes))
+		}
+		for _, e := range envelopes {
+			s, err := decryptSignature(cipher, e)
+			if err != nil {
+				t.Fatalf("%s: %s", test.name, err)
+			}
+			if s.TimestampSinceStart != 10000 || e.RequestId != 42 {
+				t.Fatalf("%s: envelope not decoded", test.name)
+			}
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	profile := testProfile(t)
+
+	tests := []struct {
+		name     string
+		modify   func(e *protos.RequestEnvelope, s *protos.Signature)
+		previous *protos.Signature
+		warning  string
+	}{
+		{"consistent", func(e *protos.RequestEnvelope, s *protos.Signature) {}, nil, ""},
+		{"request hashes", func(e *protos.RequestEnvelope, s *protos.Signature) {
+			s.RequestHash = nil
+		}, nil, "0 request hashes for 1 requests"},
+		{"unk25", func(e *protos.RequestEnvelope, s *protos.Signature) {
+			s.Unknown25++
+		}, nil, "unk25"},
+		{"previous", func(e *protos.RequestEnvelope, s *protos.Signature) {}, &protos.Signature{
+			Timestamp:           1500000000000,
+			TimestampSinceStart: 20000,
+		}, "timestamp_since_start 10000 is before the previous signature"},
+		{"fix order", func(e *protos.RequestEnvelope, s *protos.Signature) {
+			s.LocationFix[0].TimestampSnapshot = 9500
+		}, nil, "location fix 1 is before location fix 0"},
+		{"fix after signature", func(e *protos.RequestEnvelope, s *protos.Signature) {
+			s.LocationFix[1].TimestampSnapshot = 11000
+			e.MsSinceLastLocationfix = -1000
+		}, nil, "location fix 1 is after the signature"},
+		{"ms since fix", func(e *protos.RequestEnvelope, s *protos.Signature) {
+			e.MsSinceLastLocationfix = 3000
+		}, nil, "ms_since_last_locationfix 3000 does not match"},
+		{"no fix", func(e *protos.RequestEnvelope, s *protos.Signature) {
+			s.LocationFix = nil
+		}, nil, "without location fix"},
+		{"sensor", func(e *protos.RequestEnvelope, s *protos.Signature) {
+			s.SensorInfo[0].TimestampSnapshot = 10500
+		}, nil, "sensor info 0 is after the signature"},
+		{"device", func(e *protos.RequestEnvelope, s *protos.Signature) {
+			s.DeviceInfo = nil
+		}, nil, "no device info"},
+	}
+
+	for _, test := range tests {
+		s := testSignature(profile)
+		envelope, _ := testEnvelope(t, s)
+		test.modify(envelope, s)
+
+		warnings := check(envelope, s, test.previous, profile)
+		if test.warning == "" {
+			if len(warnings) != 0 {
+				t.Fatalf("%s: unexpected warnings %v", test.name, warnings)
+			}
+			continue
+		}
+		if len(warnings) != 1 || !strings.Contains(warnings[0], test.warning) {
+			t.Fatalf("%s: expected a %q warning, got %v", test.name, test.warning, warnings)
+		}
+	}
+}
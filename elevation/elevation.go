@@ -0,0 +1,75 @@
+package elevation
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+)
+
+var ErrNoData = errors.New("No elevation data for this position")
+
+// Provider returns the ground elevation in meters at a position
+type Provider interface {
+	Elevation(ctx context.Context, lat, lng float64) (float64, error)
+}
+
+// Positions closer than this many degrees (about 11 m) share a cache entry
+const cachePrecision = 1e-4
+
+type cacheKey struct {
+	lat, lng int64
+}
+
+// Cached remembers the elevations returned by another provider, so moving
+// around the same area does not query it again. Errors are not cached.
+type Cached struct {
+	provider Provider
+	size     int
+
+	mu    sync.Mutex
+	cache map[cacheKey]float64
+	order []cacheKey
+}
+
+// NewCached wraps provider keeping up to size elevations, the oldest ones are
+// dropped first
+func NewCached(provider Provider, size int) *Cached {
+	return &Cached{
+		provider: provider,
+		size:     size,
+		cache:    map[cacheKey]float64{},
+	}
+}
+
+func (c *Cached) Elevation(ctx context.Context, lat, lng float64) (float64, error) {
+	key := cacheKey{
+		lat: int64(math.Floor(lat / cachePrecision)),
+		lng: int64(math.Floor(lng / cachePrecision)),
+	}
+
+	c.mu.Lock()
+	elevation, ok := c.cache[key]
+	c.mu.Unlock()
+	if ok {
+		return elevation, nil
+	}
+
+	elevation, err := c.provider.Elevation(ctx, lat, lng)
+	if err != nil {
+		return 0, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.cache[key]; !ok {
+		c.order = append(c.order, key)
+	}
+	c.cache[key] = elevation
+	for c.size > 0 && len(c.order) > c.size {
+		delete(c.cache, c.order[0])
+		c.order = c.order[1:]
+	}
+
+	return elevation, nil
+}
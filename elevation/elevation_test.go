@@ -0,0 +1,68 @@
+package elevation
+
+import (
+	"context"
+	"encoding/binary"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSRTM(t *testing.T) {
+	dir, err := ioutil.TempDir("", "srtm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Elevation grows by one meter per column from west to east
+	data := make([]byte, srtm3Size*srtm3Size*2)
+	for row := 0; row < srtm3Size; row++ {
+		for col := 0; col < srtm3Size; col++ {
+			binary.BigEndian.PutUint16(data[(row*srtm3Size+col)*2:], uint16(col))
+		}
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "S23W044.hgt"), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	s := NewSRTM(dir)
+
+	elevation, err := s.Elevation(context.Background(), -22.5, -43.75)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(elevation-300) > 1e-6 {
+		t.Fatalf("Expected 300m, got %v", elevation)
+	}
+
+	if _, err := s.Elevation(context.Background(), 10, 10); err != ErrNoData {
+		t.Fatalf("Expected ErrNoData for a missing tile, got %v", err)
+	}
+}
+
+type countingProvider int
+
+func (p *countingProvider) Elevation(ctx context.Context, lat, lng float64) (float64, error) {
+	*p++
+	return 10, nil
+}
+
+func TestCached(t *testing.T) {
+	var calls countingProvider
+	c := NewCached(&calls, 1)
+
+	c.Elevation(context.Background(), 1, 1)
+	c.Elevation(context.Background(), 1.00001, 1.00001)
+	if calls != 1 {
+		t.Fatalf("Expected 1 call, got %d", calls)
+	}
+
+	c.Elevation(context.Background(), 2, 2)
+	c.Elevation(context.Background(), 1, 1)
+	if calls != 3 {
+		t.Fatalf("Expected evicted entry to be queried again, got %d calls", calls)
+	}
+}
@@ -0,0 +1,33 @@
+package elevation
+
+import (
+	"context"
+
+	"googlemaps.github.io/maps"
+)
+
+// Google queries the Google Maps Elevation API
+type Google struct {
+	client *maps.Client
+}
+
+func NewGoogle(apiKey string) (*Google, error) {
+	client, err := maps.NewClient(maps.WithAPIKey(apiKey))
+	if err != nil {
+		return nil, err
+	}
+	return &Google{client}, nil
+}
+
+func (g *Google) Elevation(ctx context.Context, lat, lng float64) (float64, error) {
+	resp, err := g.client.Elevation(ctx, &maps.ElevationRequest{
+		Locations: []maps.LatLng{{Lat: lat, Lng: lng}},
+	})
+	if err != nil {
+		return 0, err
+	}
+	if len(resp) == 0 {
+		return 0, ErrNoData
+	}
+	return resp[0].Elevation, nil
+}
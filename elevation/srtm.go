@@ -0,0 +1,137 @@
+package elevation
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Samples per tile side of SRTM3 (3 arc-second) and SRTM1 (1 arc-second) tiles
+const (
+	srtm3Size = 1201
+	srtm1Size = 3601
+)
+
+// Value of the samples with no data
+const srtmVoid = -32768
+
+type srtmTile struct {
+	size    int
+	samples []int16
+}
+
+// SRTM reads elevations from the SRTM .hgt tiles in a local directory, named
+// after their south west corner like N37W122.hgt. Tiles are loaded on first
+// use and kept in memory.
+type SRTM struct {
+	dir string
+
+	mu    sync.Mutex
+	tiles map[string]*srtmTile
+}
+
+func NewSRTM(dir string) *SRTM {
+	return &SRTM{
+		dir:   dir,
+		tiles: map[string]*srtmTile{},
+	}
+}
+
+func srtmTileName(lat, lng int) string {
+	ns, ew := 'N', 'E'
+	if lat < 0 {
+		ns, lat = 'S', -lat
+	}
+	if lng < 0 {
+		ew, lng = 'W', -lng
+	}
+	return fmt.Sprintf("%c%02d%c%03d.hgt", ns, lat, ew, lng)
+}
+
+// tile returns the tile named name, nil when the directory does not have it
+func (s *SRTM) tile(name string) (*srtmTile, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if t, ok := s.tiles[name]; ok {
+		return t, nil
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(s.dir, name))
+	if os.IsNotExist(err) {
+		s.tiles[name] = nil
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var size int
+	switch len(data) {
+	case srtm3Size * srtm3Size * 2:
+		size = srtm3Size
+	case srtm1Size * srtm1Size * 2:
+		size = srtm1Size
+	default:
+		return nil, fmt.Errorf("%s: invalid SRTM tile size %d", name, len(data))
+	}
+
+	t := &srtmTile{size: size, samples: make([]int16, size*size)}
+	for i := range t.samples {
+		t.samples[i] = int16(binary.BigEndian.Uint16(data[i*2:]))
+	}
+	s.tiles[name] = t
+
+	return t, nil
+}
+
+// Elevation interpolates the four samples around the position, ignoring voids
+func (s *SRTM) Elevation(ctx context.Context, lat, lng float64) (float64, error) {
+	if lat < -90 || lat > 90 || lng < -180 || lng > 180 {
+		return 0, ErrNoData
+	}
+
+	south, west := math.Floor(lat), math.Floor(lng)
+	t, err := s.tile(srtmTileName(int(south), int(west)))
+	if err != nil {
+		return 0, err
+	}
+	if t == nil {
+		return 0, ErrNoData
+	}
+
+	// Rows go from north to south and columns from west to east
+	last := float64(t.size - 1)
+	y := (south + 1 - lat) * last
+	x := (lng - west) * last
+	row, col := int(math.Min(math.Floor(y), last-1)), int(math.Min(math.Floor(x), last-1))
+	dy, dx := y-float64(row), x-float64(col)
+
+	var sum, weights float64
+	for _, p := range []struct {
+		row, col int
+		weight   float64
+	}{
+		{row, col, (1 - dy) * (1 - dx)},
+		{row, col + 1, (1 - dy) * dx},
+		{row + 1, col, dy * (1 - dx)},
+		{row + 1, col + 1, dy * dx},
+	} {
+		v := t.samples[p.row*t.size+p.col]
+		if v == srtmVoid {
+			continue
+		}
+		sum += float64(v) * p.weight
+		weights += p.weight
+	}
+	if weights == 0 {
+		return 0, ErrNoData
+	}
+
+	return sum / weights, nil
+}
@@ -42,11 +42,11 @@ func main() {
 	longitude := -1.234
 	radius := 150.0
 
-	cli.SetPosition(latitude, longitude, 0, 0)
+	cli.SetPosition(ctx, latitude, longitude, 0, 0)
 	cli.Init(ctx)
 
 	cells := helpers.GetCellsFromRadius(latitude, longitude, radius, 17)
-	_, err = cli.GetMapObjects(ctx, cells, make([]int64, len(cells)))
+	_, err = cli.GetMapObjects(ctx, cells, nil)
 	if err != nil {
 		log.Fatalf("Failed to load map objects: %v\n", err)
 	}
@@ -2,22 +2,39 @@ package buddyauth
 
 import (
 	"bytes"
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"math"
 	"net/http"
-	"sort"
 	"strconv"
 	"sync"
 	"time"
+
+	"github.com/globalpokecache/pogobuf-go/clock"
+	"github.com/globalpokecache/pogobuf-go/hash/hashkey"
+	apiversions "github.com/globalpokecache/pogobuf-go/versions"
+)
+
+// Scheduling is what Hash does when every key used its requests per minute
+type Scheduling int
+
+const (
+	// FailFast returns ErrNoAvailableKey right away
+	FailFast Scheduling = iota
+	// Block waits for the first key reset, unless the context ends before
+	Block
 )
 
 type Provider struct {
-	version   int
-	keysMutex sync.RWMutex
-	keys      []*BuddyKey
+	version    int
+	clock      clock.Clock
+	scheduling Scheduling
+	keysMutex  sync.RWMutex
+	keys       []*BuddyKey
+	events     hashkey.Feed
 	http.Client
 }
 
@@ -45,24 +62,20 @@ var (
 	ErrNoAvailableKey  = errors.New("No hash key is available")
 )
 
-var (
-	versions map[string]string
-	Debug    bool
-)
+var Debug bool
 
 func NewProvider(apiVersion int) (*Provider, error) {
-	resp, err := http.Get("https://pokehash.buddyauth.com/api/hash/versions")
+	profile, err := apiversions.Get(apiVersion)
 	if err != nil {
-		return nil, errors.New("Failed to load buddyauth versions")
+		return nil, err
 	}
-
-	err = json.NewDecoder(resp.Body).Decode(&versions)
-	if err != nil {
-		return nil, errors.New("Failed to load buddyauth versions")
+	if profile.HashEndpoints["buddyauth"] == "" {
+		return nil, fmt.Errorf("No buddyauth endpoint for version %s", profile.Name())
 	}
 
 	provider := &Provider{
 		version: apiVersion,
+		clock:   clock.Real{},
 		keys:    []*BuddyKey{},
 		Client: http.Client{
 			Timeout: 10 * time.Second,
@@ -77,11 +90,33 @@ type BuddyKey struct {
 	RPM       int
 	Used      int
 	NextReset time.Time
+	Expiry    time.Time
 	Invalid   bool
 	Expired   bool
 	sync.RWMutex
 }
 
+// Status returns a snapshot of the key
+func (b *BuddyKey) Status() hashkey.Status {
+	b.RLock()
+	defer b.RUnlock()
+
+	remaining := b.RPM - b.Used
+	if remaining < 0 {
+		remaining = 0
+	}
+	return hashkey.Status{
+		ID:        hashkey.Redact(b.Key),
+		RPM:       b.RPM,
+		Used:      b.Used,
+		Remaining: remaining,
+		NextReset: b.NextReset,
+		Expiry:    b.Expiry,
+		Invalid:   b.Invalid,
+		Expired:   b.Expired,
+	}
+}
+
 func (b *BuddyKey) GetRPM() int {
 	b.RLock()
 	defer b.RUnlock()
@@ -89,9 +124,9 @@ func (b *BuddyKey) GetRPM() int {
 }
 
 func (b *BuddyKey) SetRPM(n int) {
-	b.RLock()
+	b.Lock()
 	b.RPM = n
-	b.RUnlock()
+	b.Unlock()
 }
 
 func (b *BuddyKey) GetUsed() int {
@@ -101,9 +136,9 @@ func (b *BuddyKey) GetUsed() int {
 }
 
 func (b *BuddyKey) AddUsed(n int) {
-	b.RLock()
+	b.Lock()
 	b.Used += n
-	b.RUnlock()
+	b.Unlock()
 }
 
 func (b *BuddyKey) ResetUsed() {
@@ -119,9 +154,9 @@ func (b *BuddyKey) IsExpired() bool {
 }
 
 func (b *BuddyKey) SetExpired(v bool) {
-	b.RLock()
+	b.Lock()
 	b.Expired = v
-	b.RUnlock()
+	b.Unlock()
 }
 
 func (b *BuddyKey) IsInvalid() bool {
@@ -131,9 +166,30 @@ func (b *BuddyKey) IsInvalid() bool {
 }
 
 func (b *BuddyKey) SetInvalid(v bool) {
-	b.RLock()
+	b.Lock()
 	b.Invalid = v
-	b.RUnlock()
+	b.Unlock()
+}
+
+func (b *BuddyKey) GetExpiry() time.Time {
+	b.RLock()
+	defer b.RUnlock()
+	return b.Expiry
+}
+
+func (b *BuddyKey) SetExpiry(t time.Time) {
+	b.Lock()
+	b.Expiry = t
+	b.Unlock()
+}
+
+// setFlag sets Expired or Invalid and reports whether it changed
+func (b *BuddyKey) setFlag(flag *bool) bool {
+	b.Lock()
+	defer b.Unlock()
+	changed := !*flag
+	*flag = true
+	return changed
 }
 
 func (b *BuddyKey) GetNextReset() time.Time {
@@ -143,37 +199,51 @@ func (b *BuddyKey) GetNextReset() time.Time {
 }
 
 func (b *BuddyKey) SetNextReset(t time.Time) {
-	b.RLock()
+	b.Lock()
 	b.NextReset = t
-	b.RUnlock()
-}
-
-type byRPM []*BuddyKey
-
-func (a byRPM) Len() int      { return len(a) }
-func (a byRPM) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
-func (a byRPM) Less(i, j int) bool {
-	if a[i].GetRPM() > a[j].GetRPM() {
-		return true
-	} else if a[i].GetRPM() == a[j].GetRPM() {
-		return a[i].GetUsed() > a[j].GetUsed()
-	}
-	return false
+	b.Unlock()
 }
 
+// GetKeys returns the *BuddyKey of every key, KeyStatus gives snapshots
+// safe to keep
 func (p *Provider) GetKeys() []interface{} {
-	p.keysMutex.Lock()
-	defer p.keysMutex.Unlock()
+	p.keysMutex.RLock()
+	defer p.keysMutex.RUnlock()
 
 	keys := []interface{}{}
 	for _, key := range p.keys {
-		key.Lock()
 		keys = append(keys, key)
-		key.Unlock()
 	}
 	return keys
 }
 
+// KeyStatus returns a snapshot of every key
+func (p *Provider) KeyStatus() []hashkey.Status {
+	p.keysMutex.RLock()
+	defer p.keysMutex.RUnlock()
+
+	status := make([]hashkey.Status, len(p.keys))
+	for i, key := range p.keys {
+		status[i] = key.Status()
+	}
+	return status
+}
+
+// SubscribeKeys returns a subscription to the key events, with room for
+// buffer pending events
+func (p *Provider) SubscribeKeys(buffer int) *hashkey.Subscription {
+	return p.events.Subscribe(buffer)
+}
+
+func (p *Provider) publish(t hashkey.EventType, key *BuddyKey) {
+	debug("Key %s: %s", t, key.Key)
+	p.events.Publish(hashkey.Event{
+		Type: t,
+		Time: p.clock.Now(),
+		Key:  key.Status(),
+	})
+}
+
 func (p *Provider) AddKey(key string) error {
 	p.keysMutex.Lock()
 	defer p.keysMutex.Unlock()
@@ -195,8 +265,6 @@ func (p *Provider) AddKey(key string) error {
 		Used: 0,
 	})
 
-	sort.Sort(byRPM(p.keys))
-
 	return nil
 }
 
@@ -216,65 +284,102 @@ func (p *Provider) DelKey(key string) error {
 	}
 	p.keys = append(p.keys[:index], p.keys[index+1:]...)
 
-	sort.Sort(byRPM(p.keys))
-
 	return nil
 }
 
+// ApiURL returns the hash endpoint of the version profile
 func (p *Provider) ApiURL() string {
-	major := math.Floor(float64(p.version) / 100.0)
-	minor := (float64(p.version)/100.0 - major) * 100
-	v := fmt.Sprintf("0.%.f.%.f", major, minor)
-	return fmt.Sprintf("http://pokehash.buddyauth.com/%s", versions[v])
+	profile, _ := apiversions.Get(p.version)
+	return fmt.Sprintf("http://pokehash.buddyauth.com/%s", profile.HashEndpoints["buddyauth"])
 }
 
+// Local guess of the rate period when the server did not send its end
+const ratePeriod = 1 * time.Minute
+
+// GetAvailableKey returns the usable key with the most requests left
 func (p *Provider) GetAvailableKey() (*BuddyKey, error) {
+	key, _, err := p.nextKey()
+	return key, err
+}
+
+// nextKey picks the usable key with the most requests left. When every key
+// used its quota it returns the time of the first reset instead, a zero time
+// meaning no key will become usable.
+func (p *Provider) nextKey() (*BuddyKey, time.Time, error) {
 	p.keysMutex.Lock()
 	defer p.keysMutex.Unlock()
 
-	var key *BuddyKey
-	var found bool
+	now := p.clock.Now()
+	var best *BuddyKey
+	var bestRemaining int
+	var reset time.Time
 	debug("Searching for available key")
 	var errs []string
-	for i := 0; i < len(p.keys); i++ {
-		key = p.keys[i]
-
-		if key.NextReset.Before(time.Now().UTC()) {
+	for _, key := range p.keys {
+		if !key.GetNextReset().After(now) {
 			debug("Resetting key: %s", key.Key)
-			key.SetNextReset(time.Now().UTC().Add(1 * time.Minute))
+			key.SetNextReset(now.Add(ratePeriod))
 			key.ResetUsed()
 		}
 
-		if !key.IsExpired() && !key.IsInvalid() && key.GetUsed() < key.GetRPM() {
-			debug("Found valid key: %s", key.Key)
-			found = true
-			break
-		}
-
 		if key.IsExpired() {
-			errs = append(errs, fmt.Sprintf("%s:%s", key.Key[0:6]+"..."+key.Key[len(key.Key)-6:len(key.Key)-1], "EXPIRED_KEY"))
+			errs = append(errs, fmt.Sprintf("%s:%s", hashkey.Redact(key.Key), "EXPIRED_KEY"))
+			continue
 		}
-
 		if key.IsInvalid() {
-			errs = append(errs, fmt.Sprintf("%s:%s", key.Key[0:6]+"..."+key.Key[len(key.Key)-6:len(key.Key)-1], "INVALID_KEY"))
+			errs = append(errs, fmt.Sprintf("%s:%s", hashkey.Redact(key.Key), "INVALID_KEY"))
+			continue
 		}
 
-		if key.GetUsed() >= key.GetRPM() {
-			errs = append(errs, fmt.Sprintf("%s:%s", key.Key[0:6]+"..."+key.Key[len(key.Key)-6:len(key.Key)-1], "EXHAUSTED_KEY"))
+		remaining := key.GetRPM() - key.GetUsed()
+		if remaining <= 0 {
+			errs = append(errs, fmt.Sprintf("%s:%s", hashkey.Redact(key.Key), "EXHAUSTED_KEY"))
+			if next := key.GetNextReset(); reset.IsZero() || next.Before(reset) {
+				reset = next
+			}
+			continue
 		}
 
-		debug("Skipping invalid key: %s", key.Key)
+		if best == nil || remaining > bestRemaining {
+			best, bestRemaining = key, remaining
+		}
 	}
-	if !found {
+	if best == nil {
 		debug("No valid key found")
-		return nil, fmt.Errorf("%s: %v", ErrNoAvailableKey, errs)
+		return nil, reset, fmt.Errorf("%s: %v", ErrNoAvailableKey, errs)
 	}
 
-	key.AddUsed(1)
-	return key, nil
+	debug("Found valid key: %s", best.Key)
+	best.AddUsed(1)
+	if bestRemaining == 1 {
+		p.publish(hashkey.Exhausted, best)
+	}
+	return best, time.Time{}, nil
 }
 
-func (p *Provider) hashRequest(hashReq HashRequest, key *BuddyKey) (HashResponse, error) {
+// waitKey returns a key, waiting for a reset within the context in the Block
+// scheduling
+func (p *Provider) waitKey(ctx context.Context) (*BuddyKey, error) {
+	for {
+		key, reset, err := p.nextKey()
+		if err == nil || p.scheduling != Block || reset.IsZero() {
+			return key, err
+		}
+
+		if deadline, ok := ctx.Deadline(); ok && deadline.Before(reset) {
+			return nil, err
+		}
+
+		debug("Waiting for key reset at %s", reset)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-p.clock.After(reset.Sub(p.clock.Now())):
+		}
+	}
+}
+
+func (p *Provider) hashRequest(ctx context.Context, hashReq HashRequest, key *BuddyKey) (HashResponse, error) {
 	var hresp HashResponse
 
 	requestBytes, err := json.Marshal(&hashReq)
@@ -290,6 +395,7 @@ func (p *Provider) hashRequest(hashReq HashRequest, key *BuddyKey) (HashResponse
 		return hresp, fmt.Errorf("Failed to create request: %s", err)
 	}
 
+	req = req.WithContext(ctx)
 	req.Close = true
 	req.Header.Set("content-type", "application/json")
 	req.Header.Set("X-AuthToken", key.Key)
@@ -301,15 +407,22 @@ func (p *Provider) hashRequest(hashReq HashRequest, key *BuddyKey) (HashResponse
 	defer resp.Body.Close()
 
 	if resp.Header.Get("X-Maxrequestcount") != "" {
-		// rateperiodend, _ := strconv.ParseInt(resp.Header.Get("X-Rateperiodend"), 10, 64)
+		rateperiodend, _ := strconv.ParseInt(resp.Header.Get("X-Rateperiodend"), 10, 64)
 		maxrequestcount, _ := strconv.ParseInt(resp.Header.Get("X-Maxrequestcount"), 10, 64)
 		remaining, _ := strconv.ParseInt(resp.Header.Get("X-Raterequestsremaining"), 10, 64)
 		authtokenexpiration, _ := strconv.ParseInt(resp.Header.Get("X-Authtokenexpiration"), 10, 64)
 		debug("Updating key info: %s", key.Key)
 		debug("Received header:", resp.Header)
 
-		if time.Unix(authtokenexpiration, -1).Before(time.Now()) {
-			key.SetExpired(true)
+		if authtokenexpiration > 0 {
+			key.SetExpiry(time.Unix(authtokenexpiration, 0))
+			if time.Unix(authtokenexpiration, -1).Before(p.clock.Now()) && key.setFlag(&key.Expired) {
+				p.publish(hashkey.Expired, key)
+			}
+		}
+
+		if rateperiodend > 0 {
+			key.SetNextReset(time.Unix(rateperiodend, 0))
 		}
 
 		if key.GetRPM() != int(maxrequestcount) {
@@ -331,10 +444,22 @@ func (p *Provider) hashRequest(hashReq HashRequest, key *BuddyKey) (HashResponse
 	case http.StatusBadRequest, http.StatusNotFound:
 		return hresp, ErrBadRequest
 	case http.StatusUnauthorized:
-		key.SetInvalid(true)
+		if key.setFlag(&key.Invalid) {
+			p.publish(hashkey.Rejected, key)
+		}
 		return hresp, ErrInvalidKey
 	case 429:
 		debug("Key passed limit: %s", key.Key)
+		exhausted := key.GetUsed() >= key.GetRPM()
+		key.ResetUsed()
+		key.AddUsed(key.GetRPM())
+		// Without a reset ahead the key would be tried again right away
+		if !key.GetNextReset().After(p.clock.Now()) {
+			key.SetNextReset(p.clock.Now().Add(ratePeriod))
+		}
+		if !exhausted {
+			p.publish(hashkey.Exhausted, key)
+		}
 		return hresp, ErrKeyPassedLimit
 	}
 
@@ -347,6 +472,12 @@ func (p *Provider) hashRequest(hashReq HashRequest, key *BuddyKey) (HashResponse
 }
 
 func (p *Provider) Hash(authTicket, sessionData []byte, latitude, longitude, accuracy float64, timestamp uint64, requests [][]byte) (uint32, uint32, []uint64, error) {
+	return p.HashContext(context.Background(), authTicket, sessionData, latitude, longitude, accuracy, timestamp, requests)
+}
+
+// HashContext is Hash giving up when the context ends, which bounds the wait
+// for a key in the Block scheduling
+func (p *Provider) HashContext(ctx context.Context, authTicket, sessionData []byte, latitude, longitude, accuracy float64, timestamp uint64, requests [][]byte) (uint32, uint32, []uint64, error) {
 	baseAuthTicket := base64.StdEncoding.EncodeToString(authTicket)
 	baseSessionData := base64.StdEncoding.EncodeToString(sessionData)
 
@@ -369,9 +500,19 @@ func (p *Provider) Hash(authTicket, sessionData []byte, latitude, longitude, acc
 	var hashResp HashResponse
 	var key *BuddyKey
 
+	p.keysMutex.RLock()
+	tries := len(p.keys)
+	p.keysMutex.RUnlock()
+
+	// Each key is tried once, in the Block scheduling a key over its limit
+	// waits for its reset until the context ends. Every wait, in waitKey or
+	// between the tries, also ends with the context.
 	var success bool
-	for i := 0; i < len(p.keys); i++ {
-		key, err = p.GetAvailableKey()
+	for i := 0; i < tries || (p.scheduling == Block && err == ErrKeyPassedLimit); i++ {
+		if ctx.Err() != nil {
+			return 0, 0, []uint64{}, ctx.Err()
+		}
+		key, err = p.waitKey(ctx)
 		if err != nil {
 			return 0, 0, []uint64{0}, err
 		}
@@ -380,7 +521,7 @@ func (p *Provider) Hash(authTicket, sessionData []byte, latitude, longitude, acc
 			d, _ := json.MarshalIndent(hashReq, "", "\t")
 			debug("Sending hash request: %s", d)
 		}
-		hashResp, err = p.hashRequest(hashReq, key)
+		hashResp, err = p.hashRequest(ctx, hashReq, key)
 		if err == nil {
 			success = true
 			if Debug {
@@ -389,9 +530,15 @@ func (p *Provider) Hash(authTicket, sessionData []byte, latitude, longitude, acc
 			}
 			break
 		}
-		key.AddUsed(-1)
+		if err != ErrKeyPassedLimit {
+			key.AddUsed(-1)
+		}
 		debug("Failed to hash request: %s", err)
-		time.Sleep(1 * time.Second)
+		select {
+		case <-ctx.Done():
+			return 0, 0, []uint64{}, ctx.Err()
+		case <-p.clock.After(1 * time.Second):
+		}
 	}
 
 	if !success {
@@ -406,6 +553,16 @@ func (p *Provider) Hash(authTicket, sessionData []byte, latitude, longitude, acc
 	return hashResp.LocationAuthHash, hashResp.LocationHash, reqHashes, nil
 }
 
+// SetClock sets the clock used for the key resets and retry delays
+func (p *Provider) SetClock(c clock.Clock) {
+	p.clock = c
+}
+
+// SetScheduling sets what Hash does when every key is exhausted
+func (p *Provider) SetScheduling(s Scheduling) {
+	p.scheduling = s
+}
+
 func (p *Provider) SetDebug(d bool) {
 	Debug = d
 }
@@ -0,0 +1,211 @@
+package buddyauth
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/globalpokecache/pogobuf-go/clock"
+	"github.com/globalpokecache/pogobuf-go/hash/hashkey"
+)
+
+func testProvider(c clock.Clock, keys ...*BuddyKey) *Provider {
+	return &Provider{version: 5500, clock: c, keys: keys}
+}
+
+func TestApiURL(t *testing.T) {
+	p, err := NewProvider(5500)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if url := p.ApiURL(); url != "http://pokehash.buddyauth.com/api/v125/hash" {
+		t.Fatalf("Unexpected endpoint %s", url)
+	}
+	if _, err := NewProvider(9999); err == nil {
+		t.Fatal("Expected an unknown version to be rejected")
+	}
+}
+
+func TestNextKey(t *testing.T) {
+	c := clock.NewFake(time.Unix(1000, 0))
+	reset := c.Now().Add(30 * time.Second)
+	p := testProvider(c,
+		&BuddyKey{Key: "big-but-busy-key", RPM: 300, Used: 290, NextReset: reset},
+		&BuddyKey{Key: "small-idle-key-00", RPM: 150, Used: 10, NextReset: reset},
+	)
+
+	key, err := p.GetAvailableKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key.Key != "small-idle-key-00" {
+		t.Fatalf("Expected the key with the most requests left, got %s", key.Key)
+	}
+}
+
+func TestWaitKey(t *testing.T) {
+	c := clock.NewFake(time.Unix(1000, 0))
+	reset := c.Now().Add(30 * time.Second)
+	p := testProvider(c, &BuddyKey{Key: "exhausted-key-000", RPM: 150, Used: 150, NextReset: reset})
+
+	if _, err := p.waitKey(context.Background()); err == nil {
+		t.Fatal("FailFast should not wait for a key")
+	}
+
+	p.SetScheduling(Block)
+	ctx, cancel := context.WithDeadline(context.Background(), reset.Add(-time.Second))
+	defer cancel()
+	if _, err := p.waitKey(ctx); err == nil {
+		t.Fatal("Block should not wait past the deadline")
+	}
+
+	c.SetAutoAdvance(true)
+	key, err := p.waitKey(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key.GetUsed() != 1 || c.Now().Before(reset) {
+		t.Fatal("Key was not reset before being used")
+	}
+}
+
+func TestKeyEvents(t *testing.T) {
+	c := clock.NewFake(time.Unix(1000, 0))
+	p := testProvider(c, &BuddyKey{Key: "almost-used-key-0", RPM: 150, Used: 149, NextReset: c.Now().Add(time.Minute)})
+
+	sub := p.SubscribeKeys(1)
+	defer sub.Close()
+
+	if _, err := p.GetAvailableKey(); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case e := <-sub.Events():
+		if e.Type != hashkey.Exhausted || e.Key.Remaining != 0 || e.Key.ID != "almo...ey-0" {
+			t.Fatalf("Unexpected event %+v", e)
+		}
+	default:
+		t.Fatal("No exhausted event")
+	}
+
+	status := p.KeyStatus()
+	if len(status) != 1 || status[0].Used != 150 {
+		t.Fatalf("Unexpected status %+v", status)
+	}
+}
+
+// limitTransport answers 429 to the first hashes, then a hash
+type limitTransport struct {
+	clock   clock.Clock
+	limited int
+	calls   int
+}
+
+func (l *limitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	l.calls++
+	header := http.Header{}
+	header.Set("X-Maxrequestcount", "150")
+	header.Set("X-Rateperiodend", strconv.FormatInt(l.clock.Now().Add(time.Minute).Unix(), 10))
+	header.Set("X-Authtokenexpiration", strconv.FormatInt(l.clock.Now().Add(24*time.Hour).Unix(), 10))
+
+	status, body := http.StatusOK, `{"locationAuthHash": 1, "locationHash": 2, "RequestHashes": [3]}`
+	if l.calls <= l.limited {
+		status, body = 429, ""
+		header.Set("X-Raterequestsremaining", "0")
+	} else {
+		header.Set("X-Raterequestsremaining", "149")
+	}
+	return &http.Response{
+		StatusCode: status,
+		Header:     header,
+		Body:       ioutil.NopCloser(strings.NewReader(body)),
+		Request:    req,
+	}, nil
+}
+
+func TestBlockOnLimit(t *testing.T) {
+	c := clock.NewFake(time.Unix(1000, 0))
+	c.SetAutoAdvance(true)
+	transport := &limitTransport{clock: c, limited: 1}
+	p := testProvider(c, &BuddyKey{Key: "single-busy-key-0", RPM: 150, NextReset: c.Now().Add(time.Minute)})
+	p.Client.Transport = transport
+
+	if _, _, _, err := p.Hash(nil, nil, 0, 0, 0, 0, nil); err != ErrKeyPassedLimit {
+		t.Fatalf("FailFast should give up on the limited key, got %v", err)
+	}
+
+	// The only key is over its limit again, the hash must wait for its reset
+	transport.calls, transport.limited = 0, 2
+	p.SetScheduling(Block)
+	start := c.Now()
+	loc, _, _, err := p.Hash(nil, nil, 0, 0, 0, 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loc != 1 || transport.calls != 3 {
+		t.Fatalf("Expected the hash after two limits, got %d after %d calls", loc, transport.calls)
+	}
+	if waited := c.Now().Sub(start); waited < 2*time.Minute {
+		t.Fatalf("Hash waited %s, expected the two key resets", waited)
+	}
+
+	ctx, cancel := context.WithDeadline(context.Background(), c.Now().Add(30*time.Second))
+	defer cancel()
+	transport.calls, transport.limited = 0, 1
+	if _, _, _, err := p.HashContext(ctx, nil, nil, 0, 0, 0, 0, nil); err == nil {
+		t.Fatal("Block should not wait past the deadline")
+	}
+}
+
+// waitBlocked waits for the provider to wait on the fake clock
+func waitBlocked(t *testing.T, c *clock.Fake) {
+	for start := time.Now(); c.Waiters() == 0; time.Sleep(time.Millisecond) {
+		if time.Since(start) > time.Second {
+			t.Fatal("Hash did not wait on the clock")
+		}
+	}
+}
+
+func TestCancelExhausted(t *testing.T) {
+	c := clock.NewFake(time.Unix(1000, 0))
+	reset := c.Now().Add(time.Minute)
+	transport := &limitTransport{clock: c, limited: 1 << 30}
+	p := testProvider(c,
+		&BuddyKey{Key: "exhausted-key-one", RPM: 150, Used: 150, NextReset: reset},
+		&BuddyKey{Key: "exhausted-key-two", RPM: 150, Used: 150, NextReset: reset},
+	)
+	p.Client.Transport = transport
+	p.SetScheduling(Block)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		_, _, _, err := p.HashContext(ctx, nil, nil, 0, 0, 0, 0, nil)
+		done <- err
+	}()
+
+	// Every key stays over its limit for three minutes
+	for i := 0; i < 180; i++ {
+		waitBlocked(t, c)
+		c.Advance(time.Second)
+	}
+	waitBlocked(t, c)
+	cancel()
+
+	select {
+	case err := <-done:
+		if err != context.Canceled {
+			t.Fatalf("Expected the context error, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Hash did not end with its context")
+	}
+	if transport.calls == 0 || transport.calls > 8 {
+		t.Fatalf("Exhausted keys were tried %d times in three minutes", transport.calls)
+	}
+}
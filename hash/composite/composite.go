@@ -0,0 +1,460 @@
+// Package composite spreads the hashing over several hash providers, failing
+// over to the next one when a provider errors and taking unhealthy providers
+// out of the rotation for a while.
+package composite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/globalpokecache/pogobuf-go/clock"
+	"github.com/globalpokecache/pogobuf-go/hash"
+	"github.com/globalpokecache/pogobuf-go/hash/hashkey"
+)
+
+// Order is how the backends are tried for each hash
+type Order int
+
+const (
+	// Priority tries the backends in the order they were given
+	Priority Order = iota
+	// Weighted shuffles the backends at each hash, the ones with more
+	// weight being tried first more often
+	Weighted
+)
+
+// State of the circuit breaker of a backend
+type State int
+
+const (
+	// Closed backends are used normally
+	Closed State = iota
+	// Open backends are skipped until their retry time
+	Open
+	// HalfOpen backends are tried again by a single hash at a time, a
+	// success closes them
+	HalfOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case Closed:
+		return "closed"
+	case Open:
+		return "open"
+	case HalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+var (
+	ErrNoBackend        = errors.New("No hash backend")
+	ErrDuplicateBackend = errors.New("Hash backend name used twice")
+	ErrAllBackendsOpen  = errors.New("Every hash backend is unavailable")
+	ErrUnknownBackend   = errors.New("Unknown hash backend")
+)
+
+// Backend is a hash provider of the composite
+type Backend struct {
+	Name     string
+	Provider hash.Provider
+	// Weight in Weighted order, defaults to 1
+	Weight int
+}
+
+// Result tells which backend served a hash, or failed to
+type Result struct {
+	Backend string
+	Latency time.Duration
+	Err     error
+	// Attempt of the backend in its Hash call, starting at 0
+	Attempt int
+}
+
+// BackendStatus is the health of a backend at one moment
+type BackendStatus struct {
+	Name      string
+	State     State
+	Failures  int
+	RetryAt   time.Time
+	Served    int
+	Failed    int
+	LastError error
+}
+
+type Options struct {
+	Order Order
+	// Consecutive failures opening the circuit of a backend, defaults to 3
+	FailureThreshold int
+	// Hashes slower than this count as failures, zero disables the check
+	LatencyThreshold time.Duration
+	// How long an open backend is skipped, defaults to 30 seconds
+	OpenDuration time.Duration
+	// OnResult is called after every backend attempt
+	OnResult func(Result)
+	Clock    clock.Clock
+	Rand     rand.Source
+}
+
+const (
+	defaultFailureThreshold = 3
+	defaultOpenDuration     = 30 * time.Second
+	// relayBuffer is the room of the subscriptions to the backend key events
+	relayBuffer = 16
+)
+
+type backend struct {
+	Backend
+	state     State
+	failures  int
+	retryAt   time.Time
+	served    int
+	failed    int
+	lastError error
+	// probing is set while a hash tries the half open backend
+	probing bool
+}
+
+// Provider is a hash.Provider over several backends
+type Provider struct {
+	options  Options
+	mu       sync.Mutex
+	backends []*backend
+	rand     *rand.Rand
+	last     string
+	events   hashkey.Feed
+	relays   []*hashkey.Subscription
+	relayWg  sync.WaitGroup
+	close    sync.Once
+}
+
+func New(backends []Backend, options Options) (*Provider, error) {
+	if len(backends) == 0 {
+		return nil, ErrNoBackend
+	}
+	if options.FailureThreshold <= 0 {
+		options.FailureThreshold = defaultFailureThreshold
+	}
+	if options.OpenDuration <= 0 {
+		options.OpenDuration = defaultOpenDuration
+	}
+	if options.Clock == nil {
+		options.Clock = clock.Real{}
+	}
+	if options.Rand == nil {
+		options.Rand = rand.NewSource(time.Now().UnixNano())
+	}
+
+	p := &Provider{
+		options: options,
+		rand:    rand.New(options.Rand),
+	}
+	names := map[string]bool{}
+	for _, b := range backends {
+		if names[b.Name] {
+			return nil, fmt.Errorf("%s: %s", ErrDuplicateBackend, b.Name)
+		}
+		names[b.Name] = true
+		if b.Weight <= 0 {
+			b.Weight = 1
+		}
+		p.backends = append(p.backends, &backend{Backend: b})
+	}
+	for _, b := range p.backends {
+		if provider, ok := b.Provider.(hash.KeyStatusProvider); ok {
+			sub := provider.SubscribeKeys(relayBuffer)
+			p.relays = append(p.relays, sub)
+			p.relayWg.Add(1)
+			go p.relay(b.Name, sub)
+		}
+	}
+	return p, nil
+}
+
+// Close unsubscribes from the key events of the backends and waits for the
+// relays to end, the backends themselves are left open
+func (p *Provider) Close() {
+	p.close.Do(func() {
+		for _, sub := range p.relays {
+			sub.Close()
+		}
+		p.relayWg.Wait()
+	})
+}
+
+// relay publishes the key events of a backend until its subscription closes
+func (p *Provider) relay(name string, sub *hashkey.Subscription) {
+	defer p.relayWg.Done()
+	for e := range sub.Events() {
+		e.Key.ID = name + ":" + e.Key.ID
+		p.events.Publish(e)
+	}
+}
+
+// order returns the backends to try, closed and half open ones first
+func (p *Provider) order() []*backend {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := p.options.Clock.Now()
+	var candidates []*backend
+	for _, b := range p.backends {
+		if b.state == Open && !now.Before(b.retryAt) {
+			b.state = HalfOpen
+		}
+		if b.state == Closed || (b.state == HalfOpen && !b.probing) {
+			candidates = append(candidates, b)
+		}
+	}
+
+	if p.options.Order == Weighted {
+		// Weighted sampling without replacement
+		pool := candidates
+		candidates = make([]*backend, 0, len(pool))
+		for len(pool) > 0 {
+			total := 0
+			for _, b := range pool {
+				total += b.Weight
+			}
+			r := p.rand.Intn(total)
+			i := 0
+			for ; r >= pool[i].Weight; i++ {
+				r -= pool[i].Weight
+			}
+			candidates = append(candidates, pool[i])
+			pool = append(pool[:i:i], pool[i+1:]...)
+		}
+	}
+	return candidates
+}
+
+// acquire tells whether a backend can be tried now, it may have opened since
+// order and a half open backend lets a single probe through
+func (p *Provider) acquire(b *backend) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	switch b.state {
+	case Open:
+		return false
+	case HalfOpen:
+		if b.probing {
+			return false
+		}
+		b.probing = true
+	}
+	return true
+}
+
+// release ends the probe of a backend without judging it
+func (p *Provider) release(b *backend) {
+	p.mu.Lock()
+	b.probing = false
+	p.mu.Unlock()
+}
+
+// report updates the breaker of a backend after an attempt
+func (p *Provider) report(b *backend, latency time.Duration, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	b.probing = false
+
+	slow := p.options.LatencyThreshold > 0 && latency > p.options.LatencyThreshold
+	if err == nil {
+		b.served++
+		p.last = b.Name
+	} else {
+		b.failed++
+		b.lastError = err
+	}
+
+	if err != nil || slow {
+		b.failures++
+		if b.state == HalfOpen || b.failures >= p.options.FailureThreshold {
+			b.state = Open
+			b.retryAt = p.options.Clock.Now().Add(p.options.OpenDuration)
+		}
+		return
+	}
+	b.failures = 0
+	b.state = Closed
+}
+
+// Hash tries the backends in order until one succeeds
+func (p *Provider) Hash(authTicket, sessionData []byte, latitude, longitude, accuracy float64, timestamp uint64, requests [][]byte) (uint32, uint32, []uint64, error) {
+	return p.HashContext(context.Background(), authTicket, sessionData, latitude, longitude, accuracy, timestamp, requests)
+}
+
+// HashContext is Hash stopping the failover when the context ends, the
+// context is passed to the backends supporting it
+func (p *Provider) HashContext(ctx context.Context, authTicket, sessionData []byte, latitude, longitude, accuracy float64, timestamp uint64, requests [][]byte) (uint32, uint32, []uint64, error) {
+	loc1, loc2, reqs, _, err := p.HashBackend(ctx, authTicket, sessionData, latitude, longitude, accuracy, timestamp, requests)
+	return loc1, loc2, reqs, err
+}
+
+// HashBackend is HashContext also returning the name of the backend that
+// served the hash
+func (p *Provider) HashBackend(ctx context.Context, authTicket, sessionData []byte, latitude, longitude, accuracy float64, timestamp uint64, requests [][]byte) (uint32, uint32, []uint64, string, error) {
+	var errs []string
+	for attempt, b := range p.order() {
+		if err := ctx.Err(); err != nil {
+			return 0, 0, []uint64{}, "", err
+		}
+		if !p.acquire(b) {
+			continue
+		}
+
+		hashFunc := b.Provider.Hash
+		if cp, ok := b.Provider.(hash.ContextProvider); ok {
+			hashFunc = func(authTicket, sessionData []byte, latitude, longitude, accuracy float64, timestamp uint64, requests [][]byte) (uint32, uint32, []uint64, error) {
+				return cp.HashContext(ctx, authTicket, sessionData, latitude, longitude, accuracy, timestamp, requests)
+			}
+		}
+
+		start := p.options.Clock.Now()
+		loc1, loc2, reqs, err := hashFunc(authTicket, sessionData, latitude, longitude, accuracy, timestamp, requests)
+		latency := p.options.Clock.Now().Sub(start)
+
+		// A hash cut short by the context says nothing of the backend
+		canceled := err != nil && ctx.Err() != nil
+		if canceled {
+			p.release(b)
+		} else {
+			p.report(b, latency, err)
+		}
+		if p.options.OnResult != nil {
+			p.options.OnResult(Result{
+				Backend: b.Name,
+				Latency: latency,
+				Err:     err,
+				Attempt: attempt,
+			})
+		}
+
+		if err == nil {
+			return loc1, loc2, reqs, b.Name, nil
+		}
+		if canceled {
+			return 0, 0, []uint64{}, "", ctx.Err()
+		}
+		errs = append(errs, fmt.Sprintf("%s: %s", b.Name, err))
+	}
+
+	if len(errs) == 0 {
+		return 0, 0, []uint64{}, "", ErrAllBackendsOpen
+	}
+	return 0, 0, []uint64{}, "", fmt.Errorf("Every hash backend failed: %s", strings.Join(errs, ", "))
+}
+
+// LastBackend returns the name of the backend that served the last hash.
+//
+// Deprecated: with concurrent hashes it may not be the backend of the
+// caller hash, use HashBackend or Options.OnResult.
+func (p *Provider) LastBackend() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.last
+}
+
+// Status returns the health of the backends
+func (p *Provider) Status() []BackendStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	status := make([]BackendStatus, len(p.backends))
+	for i, b := range p.backends {
+		status[i] = BackendStatus{
+			Name:      b.Name,
+			State:     b.state,
+			Failures:  b.failures,
+			RetryAt:   b.retryAt,
+			Served:    b.served,
+			Failed:    b.failed,
+			LastError: b.lastError,
+		}
+	}
+	return status
+}
+
+func (p *Provider) backend(name string) (*backend, error) {
+	for _, b := range p.backends {
+		if b.Name == name {
+			return b, nil
+		}
+	}
+	return nil, fmt.Errorf("%s: %s", ErrUnknownBackend, name)
+}
+
+// route splits a "backend:key" into its backend and key, keys belong to one
+// vendor so they have to say which backend they are for
+func (p *Provider) route(key string) (*backend, string, error) {
+	i := strings.Index(key, ":")
+	if i == -1 {
+		return nil, "", errors.New("Key must be prefixed with its backend name, as in backend:key")
+	}
+	b, err := p.backend(key[:i])
+	return b, key[i+1:], err
+}
+
+// AddKey adds a "backend:key" to the named backend
+func (p *Provider) AddKey(key string) error {
+	b, key, err := p.route(key)
+	if err != nil {
+		return err
+	}
+	return b.Provider.AddKey(key)
+}
+
+// DelKey removes a "backend:key" from the named backend
+func (p *Provider) DelKey(key string) error {
+	b, key, err := p.route(key)
+	if err != nil {
+		return err
+	}
+	return b.Provider.DelKey(key)
+}
+
+// GetKeys returns the keys of every backend
+func (p *Provider) GetKeys() []interface{} {
+	keys := []interface{}{}
+	for _, b := range p.backends {
+		keys = append(keys, b.Provider.GetKeys()...)
+	}
+	return keys
+}
+
+// KeyStatus returns a snapshot of the keys of every backend reporting them,
+// their ID prefixed with the backend name
+func (p *Provider) KeyStatus() []hashkey.Status {
+	status := []hashkey.Status{}
+	for _, b := range p.backends {
+		provider, ok := b.Provider.(hash.KeyStatusProvider)
+		if !ok {
+			continue
+		}
+		for _, s := range provider.KeyStatus() {
+			s.ID = b.Name + ":" + s.ID
+			status = append(status, s)
+		}
+	}
+	return status
+}
+
+// SubscribeKeys returns a subscription to the key events of every backend,
+// with room for buffer pending events
+func (p *Provider) SubscribeKeys(buffer int) *hashkey.Subscription {
+	return p.events.Subscribe(buffer)
+}
+
+func (p *Provider) SetDebug(d bool) {
+	for _, b := range p.backends {
+		b.Provider.SetDebug(d)
+	}
+}
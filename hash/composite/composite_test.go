@@ -0,0 +1,256 @@
+package composite
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/globalpokecache/pogobuf-go/clock"
+	"github.com/globalpokecache/pogobuf-go/hash/hashkey"
+)
+
+type fakeProvider struct {
+	clock *clock.Fake
+	delay time.Duration
+	err   error
+	calls int
+	loc   uint32
+}
+
+func (f *fakeProvider) AddKey(string) error    { return nil }
+func (f *fakeProvider) DelKey(string) error    { return nil }
+func (f *fakeProvider) GetKeys() []interface{} { return nil }
+func (f *fakeProvider) SetDebug(bool)          {}
+
+func (f *fakeProvider) Hash(authTicket, sessionData []byte, latitude, longitude, accuracy float64, timestamp uint64, requests [][]byte) (uint32, uint32, []uint64, error) {
+	f.calls++
+	f.clock.Advance(f.delay)
+	return f.loc, f.loc, nil, f.err
+}
+
+func TestFailover(t *testing.T) {
+	c := clock.NewFake(time.Unix(0, 0))
+	primary := &fakeProvider{clock: c, loc: 1, err: errors.New("down")}
+	secondary := &fakeProvider{clock: c, loc: 2}
+
+	var results []Result
+	p, err := New([]Backend{
+		{Name: "primary", Provider: primary},
+		{Name: "secondary", Provider: secondary},
+	}, Options{
+		FailureThreshold: 2,
+		OpenDuration:     time.Minute,
+		OnResult:         func(r Result) { results = append(results, r) },
+		Clock:            c,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		loc, _, _, name, err := p.HashBackend(context.Background(), nil, nil, 0, 0, 0, 0, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if loc != 2 || name != "secondary" {
+			t.Fatalf("Hash %d was not served by the secondary backend", i)
+		}
+	}
+	if primary.calls != 2 {
+		t.Fatalf("Open primary backend was called %d times, expected 2", primary.calls)
+	}
+	if len(results) != 5 || results[1].Backend != "secondary" || results[1].Attempt != 1 {
+		t.Fatalf("Unexpected results %+v", results)
+	}
+	if s := p.Status()[0]; s.State != Open {
+		t.Fatalf("Primary backend is %s, expected open", s.State)
+	}
+
+	// A successful trial once the circuit half opens closes it again
+	primary.err = nil
+	c.Advance(time.Minute)
+	if loc, _, _, _ := p.Hash(nil, nil, 0, 0, 0, 0, nil); loc != 1 {
+		t.Fatal("Half open primary backend was not tried")
+	}
+	if s := p.Status()[0]; s.State != Closed {
+		t.Fatalf("Primary backend is %s, expected closed", s.State)
+	}
+}
+
+func TestLatency(t *testing.T) {
+	c := clock.NewFake(time.Unix(0, 0))
+	slow := &fakeProvider{clock: c, loc: 1, delay: 5 * time.Second}
+	fast := &fakeProvider{clock: c, loc: 2}
+
+	p, _ := New([]Backend{
+		{Name: "slow", Provider: slow},
+		{Name: "fast", Provider: fast},
+	}, Options{FailureThreshold: 1, LatencyThreshold: time.Second, Clock: c})
+
+	if loc, _, _, _ := p.Hash(nil, nil, 0, 0, 0, 0, nil); loc != 1 {
+		t.Fatal("Slow result should still be used")
+	}
+	if loc, _, _, _ := p.Hash(nil, nil, 0, 0, 0, 0, nil); loc != 2 {
+		t.Fatal("Slow backend should be open")
+	}
+}
+
+func TestWeighted(t *testing.T) {
+	c := clock.NewFake(time.Unix(0, 0))
+	heavy := &fakeProvider{clock: c}
+	light := &fakeProvider{clock: c}
+
+	p, _ := New([]Backend{
+		{Name: "light", Provider: light, Weight: 1},
+		{Name: "heavy", Provider: heavy, Weight: 9},
+	}, Options{Order: Weighted, Clock: c, Rand: rand.NewSource(1)})
+
+	for i := 0; i < 1000; i++ {
+		p.Hash(nil, nil, 0, 0, 0, 0, nil)
+	}
+	if heavy.calls < 850 || heavy.calls > 950 {
+		t.Fatalf("Heavy backend served %d of 1000 hashes", heavy.calls)
+	}
+}
+
+// probeProvider blocks its hashes until release is closed
+type probeProvider struct {
+	fakeProvider
+	entered chan struct{}
+	release chan struct{}
+}
+
+func (f *probeProvider) Hash(authTicket, sessionData []byte, latitude, longitude, accuracy float64, timestamp uint64, requests [][]byte) (uint32, uint32, []uint64, error) {
+	f.entered <- struct{}{}
+	<-f.release
+	return f.loc, f.loc, nil, nil
+}
+
+func TestHalfOpenProbe(t *testing.T) {
+	c := clock.NewFake(time.Unix(0, 0))
+	primary := &probeProvider{
+		fakeProvider: fakeProvider{loc: 1},
+		entered:      make(chan struct{}, 2),
+		release:      make(chan struct{}),
+	}
+	secondary := &fakeProvider{clock: c, loc: 2}
+
+	p, _ := New([]Backend{
+		{Name: "primary", Provider: primary},
+		{Name: "secondary", Provider: secondary},
+	}, Options{FailureThreshold: 1, OpenDuration: time.Minute, Clock: c})
+
+	b, _ := p.backend("primary")
+	p.report(b, 0, errors.New("down"))
+	c.Advance(time.Minute)
+
+	probe := make(chan uint32)
+	go func() {
+		loc, _, _, _ := p.Hash(nil, nil, 0, 0, 0, 0, nil)
+		probe <- loc
+	}()
+	<-primary.entered
+
+	// While the probe runs the other hashes go to the next backend
+	if loc, _, _, _ := p.Hash(nil, nil, 0, 0, 0, 0, nil); loc != 2 {
+		t.Fatal("Half open backend let a second hash through")
+	}
+
+	close(primary.release)
+	if loc := <-probe; loc != 1 {
+		t.Fatal("Probe was not served by the half open backend")
+	}
+	if s := p.Status()[0]; s.State != Closed {
+		t.Fatalf("Primary backend is %s, expected closed", s.State)
+	}
+}
+
+// cancelProvider cancels the hash it serves
+type cancelProvider struct {
+	fakeProvider
+	cancel func()
+}
+
+func (f *cancelProvider) HashContext(ctx context.Context, authTicket, sessionData []byte, latitude, longitude, accuracy float64, timestamp uint64, requests [][]byte) (uint32, uint32, []uint64, error) {
+	f.cancel()
+	return 0, 0, nil, ctx.Err()
+}
+
+func TestCanceledHash(t *testing.T) {
+	c := clock.NewFake(time.Unix(0, 0))
+	ctx, cancel := context.WithCancel(context.Background())
+	primary := &cancelProvider{fakeProvider: fakeProvider{clock: c}, cancel: cancel}
+
+	p, _ := New([]Backend{{Name: "primary", Provider: primary}}, Options{FailureThreshold: 1, Clock: c})
+
+	if _, _, _, err := p.HashContext(ctx, nil, nil, 0, 0, 0, 0, nil); err != context.Canceled {
+		t.Fatalf("Expected the context error, got %v", err)
+	}
+	if s := p.Status()[0]; s.State != Closed || s.Failed != 0 {
+		t.Fatalf("Canceled hash counted against the backend: %+v", s)
+	}
+}
+
+// keyProvider reports a single key
+type keyProvider struct {
+	fakeProvider
+	events hashkey.Feed
+}
+
+func (f *keyProvider) KeyStatus() []hashkey.Status {
+	return []hashkey.Status{{ID: "abcd...wxyz", RPM: 150}}
+}
+
+func (f *keyProvider) SubscribeKeys(buffer int) *hashkey.Subscription {
+	return f.events.Subscribe(buffer)
+}
+
+func TestKeyStatus(t *testing.T) {
+	c := clock.NewFake(time.Unix(0, 0))
+	keys := &keyProvider{fakeProvider: fakeProvider{clock: c}}
+
+	p, _ := New([]Backend{
+		{Name: "plain", Provider: &fakeProvider{clock: c}},
+		{Name: "keys", Provider: keys},
+	}, Options{Clock: c})
+
+	status := p.KeyStatus()
+	if len(status) != 1 || status[0].ID != "keys:abcd...wxyz" {
+		t.Fatalf("Unexpected status %+v", status)
+	}
+
+	sub := p.SubscribeKeys(1)
+	defer sub.Close()
+	keys.events.Publish(hashkey.Event{Type: hashkey.Exhausted, Key: keys.KeyStatus()[0]})
+
+	select {
+	case e := <-sub.Events():
+		if e.Type != hashkey.Exhausted || e.Key.ID != "keys:abcd...wxyz" {
+			t.Fatalf("Unexpected event %+v", e)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Backend event was not relayed")
+	}
+}
+
+func TestClose(t *testing.T) {
+	c := clock.NewFake(time.Unix(0, 0))
+	keys := &keyProvider{fakeProvider: fakeProvider{clock: c}}
+
+	p, _ := New([]Backend{{Name: "keys", Provider: keys}}, Options{Clock: c})
+	sub := p.SubscribeKeys(1)
+	defer sub.Close()
+
+	// Close returns once the relay ended
+	p.Close()
+	p.Close()
+
+	keys.events.Publish(hashkey.Event{Type: hashkey.Rejected})
+	select {
+	case e := <-sub.Events():
+		t.Fatalf("Event %+v relayed after Close", e)
+	case <-time.After(10 * time.Millisecond):
+	}
+}
@@ -1,8 +1,12 @@
 package hash
 
 import (
+	"context"
 	"errors"
+
 	"github.com/globalpokecache/pogobuf-go/hash/buddyauth"
+	"github.com/globalpokecache/pogobuf-go/hash/hashkey"
+	"github.com/globalpokecache/pogobuf-go/hash/native"
 )
 
 type Provider interface {
@@ -13,10 +17,26 @@ type Provider interface {
 	SetDebug(bool)
 }
 
+// ContextProvider is a Provider able to stop hashing when a context ends,
+// like when waiting for a key with quota left
+type ContextProvider interface {
+	Provider
+	HashContext(ctx context.Context, authTicket, sessionData []byte, latitude, longitude, accuracy float64, timestamp uint64, requests [][]byte) (uint32, uint32, []uint64, error)
+}
+
+// KeyStatusProvider is a Provider reporting the state of its keys
+type KeyStatusProvider interface {
+	Provider
+	KeyStatus() []hashkey.Status
+	SubscribeKeys(buffer int) *hashkey.Subscription
+}
+
 func NewProvider(provider string, apiVersion int) (Provider, error) {
 	switch provider {
 	case "buddyauth":
 		return buddyauth.NewProvider(apiVersion)
+	case "native":
+		return native.NewProvider(apiVersion)
 	default:
 		return nil, errors.New("Hash provider not supported")
 	}
@@ -0,0 +1,137 @@
+// Package hashkey describes the state of hash server keys, as immutable
+// snapshots and as a feed of change events.
+package hashkey
+
+import (
+	"sync"
+	"time"
+)
+
+// Status is a snapshot of a key, it doesn't change once returned
+type Status struct {
+	// ID is the key with most of it redacted
+	ID        string
+	RPM       int
+	Used      int
+	Remaining int
+	NextReset time.Time
+	// Expiry is zero until the server told it
+	Expiry  time.Time
+	Invalid bool
+	Expired bool
+}
+
+// Redact hides a key except for its first and last characters
+func Redact(key string) string {
+	if len(key) < 12 {
+		return "..."
+	}
+	return key[:4] + "..." + key[len(key)-4:]
+}
+
+// EventType is the change a key went through
+type EventType int
+
+const (
+	// Exhausted keys used their requests of the rate period
+	Exhausted EventType = iota
+	// Expired keys passed their expiration time
+	Expired
+	// Rejected keys were refused by the server as invalid
+	Rejected
+)
+
+func (t EventType) String() string {
+	switch t {
+	case Exhausted:
+		return "exhausted"
+	case Expired:
+		return "expired"
+	case Rejected:
+		return "rejected"
+	}
+	return "unknown"
+}
+
+// Event is a change of a key, with its status right after the change
+type Event struct {
+	Type EventType
+	Time time.Time
+	Key  Status
+}
+
+// Feed fans the events out to its subscriptions. Publishing never blocks the
+// hashing: events are dropped for the subscriptions whose buffer is full.
+// The zero value is ready to use.
+type Feed struct {
+	mu   sync.Mutex
+	subs []*Subscription
+}
+
+// Subscription receives the events of a Feed in order
+type Subscription struct {
+	feed    *Feed
+	events  chan Event
+	dropped uint64
+	once    sync.Once
+}
+
+// Subscribe registers a new subscription with room for buffer pending events
+func (f *Feed) Subscribe(buffer int) *Subscription {
+	if buffer < 0 {
+		buffer = 0
+	}
+	s := &Subscription{
+		feed:   f,
+		events: make(chan Event, buffer),
+	}
+
+	f.mu.Lock()
+	f.subs = append(f.subs, s)
+	f.mu.Unlock()
+
+	return s
+}
+
+// Publish delivers an event to every subscription with room for it
+func (f *Feed) Publish(e Event) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, s := range f.subs {
+		select {
+		case s.events <- e:
+		default:
+			s.dropped++
+		}
+	}
+}
+
+// Events returns the channel the subscription is fed from, it is closed by
+// Close
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+// Dropped returns how many events did not fit in the buffer
+func (s *Subscription) Dropped() uint64 {
+	s.feed.mu.Lock()
+	defer s.feed.mu.Unlock()
+	return s.dropped
+}
+
+// Close stops the subscription and closes its channel
+func (s *Subscription) Close() {
+	s.once.Do(func() {
+		s.feed.mu.Lock()
+		defer s.feed.mu.Unlock()
+
+		for i, sub := range s.feed.subs {
+			if sub == s {
+				s.feed.subs = append(s.feed.subs[:i], s.feed.subs[i+1:]...)
+				break
+			}
+		}
+		close(s.events)
+	})
+}
@@ -0,0 +1,52 @@
+package hashkey
+
+import "testing"
+
+func TestFeedDropped(t *testing.T) {
+	var f Feed
+	small := f.Subscribe(1)
+	big := f.Subscribe(3)
+	defer small.Close()
+	defer big.Close()
+
+	for i := 0; i < 3; i++ {
+		f.Publish(Event{Type: Exhausted})
+	}
+	if small.Dropped() != 2 || big.Dropped() != 0 {
+		t.Fatalf("Dropped %d and %d events, expected 2 and 0", small.Dropped(), big.Dropped())
+	}
+	if len(small.Events()) != 1 || len(big.Events()) != 3 {
+		t.Fatal("Subscriptions did not keep the events fitting their buffer")
+	}
+}
+
+func TestFeedClose(t *testing.T) {
+	var f Feed
+	closed := f.Subscribe(1)
+	open := f.Subscribe(1)
+	defer open.Close()
+
+	closed.Close()
+	closed.Close()
+	if _, ok := <-closed.Events(); ok {
+		t.Fatal("Closed subscription channel is still open")
+	}
+
+	// Publishing after a Close must skip the closed subscription
+	f.Publish(Event{Type: Expired})
+	if e := <-open.Events(); e.Type != Expired {
+		t.Fatalf("Unexpected event %+v", e)
+	}
+	if closed.Dropped() != 0 {
+		t.Fatal("Closed subscription counted a drop")
+	}
+}
+
+func TestRedact(t *testing.T) {
+	if id := Redact("0123456789abcdef"); id != "0123...cdef" {
+		t.Fatalf("Unexpected redacted key %s", id)
+	}
+	if id := Redact("short"); id != "..." {
+		t.Fatalf("Short key should be hidden, got %s", id)
+	}
+}
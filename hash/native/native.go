@@ -0,0 +1,87 @@
+// Package native computes the request hashes in process for the API versions
+// whose hashing is public. The versions before 0.45 hash with xxHash seeded
+// with the auth ticket, 0.45 and later moved to an undisclosed algorithm and
+// need a hash server.
+package native
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Seed of the xxHash hashes
+const hashSeed = 0x1B845238
+
+// Versions lists the API versions the provider can hash
+var Versions = []int{3300, 3500, 3700, 3900, 4100, 4300}
+
+var ErrUnsupportedVersion = errors.New("Native hashing is not supported for this version")
+
+// Supported reports whether an API version can be hashed natively
+func Supported(apiVersion int) bool {
+	for _, v := range Versions {
+		if v == apiVersion {
+			return true
+		}
+	}
+	return false
+}
+
+// Provider is a hash provider needing neither keys nor network
+type Provider struct {
+	version int
+}
+
+func NewProvider(apiVersion int) (*Provider, error) {
+	if !Supported(apiVersion) {
+		return nil, fmt.Errorf("%s: %d", ErrUnsupportedVersion, apiVersion)
+	}
+	return &Provider{version: apiVersion}, nil
+}
+
+// AddKey does nothing, the native provider has no key
+func (p *Provider) AddKey(string) error {
+	return nil
+}
+
+// DelKey does nothing, the native provider has no key
+func (p *Provider) DelKey(string) error {
+	return nil
+}
+
+func (p *Provider) GetKeys() []interface{} {
+	return []interface{}{}
+}
+
+func (p *Provider) SetDebug(bool) {}
+
+// Hash returns the location hash seeded with the auth ticket, the plain
+// location hash and the request hashes
+func (p *Provider) Hash(authTicket, sessionData []byte, latitude, longitude, accuracy float64, timestamp uint64, requests [][]byte) (uint32, uint32, []uint64, error) {
+	if !Supported(p.version) {
+		return 0, 0, nil, fmt.Errorf("%s: %d", ErrUnsupportedVersion, p.version)
+	}
+
+	location := locationBytes(latitude, longitude, accuracy)
+	locHash1 := xxh32(location, xxh32(authTicket, hashSeed))
+	locHash2 := xxh32(location, hashSeed)
+
+	seed := xxh64(authTicket, hashSeed)
+	reqHashes := make([]uint64, len(requests))
+	for i, req := range requests {
+		reqHashes[i] = xxh64(req, seed)
+	}
+
+	return locHash1, locHash2, reqHashes, nil
+}
+
+// locationBytes is the big endian latitude, longitude and accuracy
+func locationBytes(latitude, longitude, accuracy float64) []byte {
+	b := make([]byte, 24)
+	binary.BigEndian.PutUint64(b[0:], math.Float64bits(latitude))
+	binary.BigEndian.PutUint64(b[8:], math.Float64bits(longitude))
+	binary.BigEndian.PutUint64(b[16:], math.Float64bits(accuracy))
+	return b
+}
@@ -0,0 +1,64 @@
+package native
+
+import (
+	"testing"
+)
+
+func TestXXHash(t *testing.T) {
+	tests := []struct {
+		input string
+		h32   uint32
+		h64   uint64
+	}{
+		{"", 0x02CC5D05, 0xEF46DB3751D8E999},
+		{"abc", 0x32D153FF, 0x44BC2CF5AD770999},
+		{"Nobody inspects the spammish repetition", 0xE2293B2F, 0xFBCEA83C8A378BF1},
+	}
+	for _, test := range tests {
+		if h := xxh32([]byte(test.input), 0); h != test.h32 {
+			t.Fatalf("xxh32(%q) = %08x, expected %08x", test.input, h, test.h32)
+		}
+		if h := xxh64([]byte(test.input), 0); h != test.h64 {
+			t.Fatalf("xxh64(%q) = %016x, expected %016x", test.input, h, test.h64)
+		}
+	}
+}
+
+func TestHash(t *testing.T) {
+	if _, err := NewProvider(4500); err == nil {
+		t.Fatal("Expected an error for 0.45, it needs a hash server")
+	}
+
+	p, err := NewProvider(4300)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// No captured traffic of these versions is at hand, the expected hashes
+	// were computed with an independent xxHash implementation over the layout
+	// of the public clients: big endian location, seed 0x1B845238, location
+	// and request hashes seeded with the ticket
+	ticket := make([]byte, 120)
+	for i := range ticket {
+		ticket[i] = byte(i)
+	}
+	long := make([]byte, 48)
+	for i := range long {
+		long[i] = byte(i * 7)
+	}
+	requests := [][]byte{{0x08, 0x02}, long}
+
+	loc1, loc2, reqs, err := p.Hash(ticket, nil, 40.7829, -73.9654, 10, 0, requests)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loc1 != 0x9616281d {
+		t.Fatalf("Location hash 1 is %08x", loc1)
+	}
+	if loc2 != 0xe3de731c {
+		t.Fatalf("Location hash 2 is %08x", loc2)
+	}
+	if len(reqs) != 2 || reqs[0] != 0x9e0d3ae1c9b6a283 || reqs[1] != 0xa8f4fe2e49853d6a {
+		t.Fatalf("Request hashes are %x", reqs)
+	}
+}
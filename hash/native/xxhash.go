@@ -0,0 +1,128 @@
+package native
+
+import (
+	"encoding/binary"
+	"math/bits"
+)
+
+const (
+	prime32_1 uint32 = 2654435761
+	prime32_2 uint32 = 2246822519
+	prime32_3 uint32 = 3266489917
+	prime32_4 uint32 = 668265263
+	prime32_5 uint32 = 374761393
+
+	prime64_1 uint64 = 11400714785074694791
+	prime64_2 uint64 = 14029467366897019727
+	prime64_3 uint64 = 1609587929392839161
+	prime64_4 uint64 = 9650029242287828579
+	prime64_5 uint64 = 2870177450012600261
+)
+
+func round32(acc, lane uint32) uint32 {
+	acc += lane * prime32_2
+	acc = bits.RotateLeft32(acc, 13)
+	return acc * prime32_1
+}
+
+// xxh32 is the 32 bits xxHash of b
+func xxh32(b []byte, seed uint32) uint32 {
+	n := len(b)
+	var h uint32
+
+	if n >= 16 {
+		v1 := seed + prime32_1 + prime32_2
+		v2 := seed + prime32_2
+		v3 := seed
+		v4 := seed - prime32_1
+		for ; len(b) >= 16; b = b[16:] {
+			v1 = round32(v1, binary.LittleEndian.Uint32(b[0:]))
+			v2 = round32(v2, binary.LittleEndian.Uint32(b[4:]))
+			v3 = round32(v3, binary.LittleEndian.Uint32(b[8:]))
+			v4 = round32(v4, binary.LittleEndian.Uint32(b[12:]))
+		}
+		h = bits.RotateLeft32(v1, 1) + bits.RotateLeft32(v2, 7) +
+			bits.RotateLeft32(v3, 12) + bits.RotateLeft32(v4, 18)
+	} else {
+		h = seed + prime32_5
+	}
+
+	h += uint32(n)
+
+	for ; len(b) >= 4; b = b[4:] {
+		h += binary.LittleEndian.Uint32(b) * prime32_3
+		h = bits.RotateLeft32(h, 17) * prime32_4
+	}
+	for _, c := range b {
+		h += uint32(c) * prime32_5
+		h = bits.RotateLeft32(h, 11) * prime32_1
+	}
+
+	h ^= h >> 15
+	h *= prime32_2
+	h ^= h >> 13
+	h *= prime32_3
+	h ^= h >> 16
+	return h
+}
+
+func round64(acc, lane uint64) uint64 {
+	acc += lane * prime64_2
+	acc = bits.RotateLeft64(acc, 31)
+	return acc * prime64_1
+}
+
+func mergeRound64(acc, v uint64) uint64 {
+	acc ^= round64(0, v)
+	return acc*prime64_1 + prime64_4
+}
+
+// xxh64 is the 64 bits xxHash of b
+func xxh64(b []byte, seed uint64) uint64 {
+	n := len(b)
+	var h uint64
+
+	if n >= 32 {
+		v1 := seed + prime64_1 + prime64_2
+		v2 := seed + prime64_2
+		v3 := seed
+		v4 := seed - prime64_1
+		for ; len(b) >= 32; b = b[32:] {
+			v1 = round64(v1, binary.LittleEndian.Uint64(b[0:]))
+			v2 = round64(v2, binary.LittleEndian.Uint64(b[8:]))
+			v3 = round64(v3, binary.LittleEndian.Uint64(b[16:]))
+			v4 = round64(v4, binary.LittleEndian.Uint64(b[24:]))
+		}
+		h = bits.RotateLeft64(v1, 1) + bits.RotateLeft64(v2, 7) +
+			bits.RotateLeft64(v3, 12) + bits.RotateLeft64(v4, 18)
+		h = mergeRound64(h, v1)
+		h = mergeRound64(h, v2)
+		h = mergeRound64(h, v3)
+		h = mergeRound64(h, v4)
+	} else {
+		h = seed + prime64_5
+	}
+
+	h += uint64(n)
+
+	for ; len(b) >= 8; b = b[8:] {
+		h ^= round64(0, binary.LittleEndian.Uint64(b))
+		h = bits.RotateLeft64(h, 27)*prime64_1 + prime64_4
+	}
+	if len(b) >= 4 {
+		h ^= uint64(binary.LittleEndian.Uint32(b)) * prime64_1
+		h = bits.RotateLeft64(h, 23)*prime64_2 + prime64_3
+		b = b[4:]
+	}
+	for _, c := range b {
+		h ^= uint64(c) * prime64_5
+		h = bits.RotateLeft64(h, 11) * prime64_1
+	}
+
+	h ^= h >> 33
+	h *= prime64_2
+	h ^= h >> 29
+	h *= prime64_3
+	h ^= h >> 32
+	return h
+}
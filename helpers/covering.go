@@ -0,0 +1,139 @@
+package helpers
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/golang/geo/s2"
+)
+
+const (
+	// MaxMapCells is the most cells the server accepts in one GET_MAP_OBJECTS
+	MaxMapCells = 100
+	// MapCellLevel is the level the game client requests map cells at
+	MapCellLevel = 15
+)
+
+var (
+	ErrTooManyCells  = errors.New("Covering exceeds the cell limit")
+	ErrInvalidLevel  = errors.New("Invalid S2 cell level")
+	ErrInvalidRegion = errors.New("Invalid region")
+)
+
+// CoverOptions configures a covering, zero values fall back to a single
+// MapCellLevel level and MaxMapCells cells
+type CoverOptions struct {
+	MinLevel int
+	MaxLevel int
+	MaxCells int
+}
+
+func (o CoverOptions) withDefaults() (CoverOptions, error) {
+	if o.MinLevel == 0 && o.MaxLevel == 0 {
+		o.MinLevel = MapCellLevel
+		o.MaxLevel = MapCellLevel
+	} else if o.MaxLevel == 0 {
+		o.MaxLevel = o.MinLevel
+	}
+	if o.MaxCells <= 0 {
+		o.MaxCells = MaxMapCells
+	}
+	if o.MinLevel < 0 || o.MaxLevel > s2.MaxLevel || o.MinLevel > o.MaxLevel {
+		return o, ErrInvalidLevel
+	}
+	return o, nil
+}
+
+// CapRegion is the circle of radius meters around lat, lng
+func CapRegion(lat, lng, radius float64) s2.Region {
+	return s2.CapFromCenterAngle(s2.PointFromLatLng(s2.LatLngFromDegrees(lat, lng)), kmToAngle(radius/1000))
+}
+
+// RectRegion is the latitude/longitude rectangle spanned by two corners
+func RectRegion(lo, hi LatLng) s2.Region {
+	return s2.RectFromLatLng(s2.LatLngFromDegrees(lo.Lat, lo.Lng)).
+		AddPoint(s2.LatLngFromDegrees(hi.Lat, hi.Lng))
+}
+
+// PolygonRegion is the area enclosed by the vertices, in any winding
+func PolygonRegion(vertices []LatLng) (s2.Region, error) {
+	if len(vertices) > 1 && vertices[0] == vertices[len(vertices)-1] {
+		vertices = vertices[:len(vertices)-1]
+	}
+	if len(vertices) < 3 {
+		return nil, ErrInvalidRegion
+	}
+
+	points := make([]s2.Point, len(vertices))
+	for i, v := range vertices {
+		points[i] = s2.PointFromLatLng(s2.LatLngFromDegrees(v.Lat, v.Lng))
+	}
+	loop := s2.LoopFromPoints(points)
+	if err := loop.Validate(); err != nil {
+		return nil, fmt.Errorf("%s: %s", ErrInvalidRegion, err)
+	}
+	loop.Normalize()
+
+	return loop, nil
+}
+
+func cover(region s2.Region, opts CoverOptions, maxCells int) []uint64 {
+	rc := &s2.RegionCoverer{
+		MinLevel: opts.MinLevel,
+		MaxLevel: opts.MaxLevel,
+		LevelMod: 1,
+		MaxCells: maxCells,
+	}
+
+	covering := rc.Covering(region)
+	cells := make([]uint64, len(covering))
+	for i, id := range covering {
+		cells[i] = uint64(id)
+	}
+	sort.Sort(AscID(cells))
+
+	return cells
+}
+
+// Cover returns the cells covering the region sorted by id. It fails with
+// ErrTooManyCells instead of dropping cells when the region needs more than
+// opts.MaxCells cells.
+func Cover(region s2.Region, opts CoverOptions) ([]uint64, error) {
+	opts, err := opts.withDefaults()
+	if err != nil {
+		return nil, err
+	}
+
+	cells := cover(region, opts, opts.MaxCells)
+	if len(cells) > opts.MaxCells {
+		return nil, ErrTooManyCells
+	}
+
+	return cells, nil
+}
+
+// CoverBatches returns the whole covering of the region split in batches of at
+// most opts.MaxCells cells, each of them small enough for one request. Cells
+// are sorted by id so every batch stays spatially close together.
+func CoverBatches(region s2.Region, opts CoverOptions) ([][]uint64, error) {
+	opts, err := opts.withDefaults()
+	if err != nil {
+		return nil, err
+	}
+
+	// No cell budget, batches hold the finest covering the levels allow
+	cells := cover(region, opts, math.MaxInt32)
+
+	var batches [][]uint64
+	for len(cells) > opts.MaxCells {
+		batches = append(batches, cells[:opts.MaxCells:opts.MaxCells])
+		cells = cells[opts.MaxCells:]
+	}
+	if len(cells) > 0 {
+		batches = append(batches, cells)
+	}
+
+	return batches, nil
+}
@@ -0,0 +1,73 @@
+package helpers
+
+import (
+	"testing"
+)
+
+func TestCoverLimits(t *testing.T) {
+	cells, err := Cover(CapRegion(40.7829, -73.9654, 640), CoverOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cells) == 0 || len(cells) > MaxMapCells {
+		t.Fatalf("Unexpected covering size %d", len(cells))
+	}
+
+	region := CapRegion(40.7829, -73.9654, 5000)
+	_, err = Cover(region, CoverOptions{})
+	if err != ErrTooManyCells {
+		t.Fatalf("Expected ErrTooManyCells, got %v", err)
+	}
+
+	batches, err := CoverBatches(region, CoverOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := map[uint64]bool{}
+	for _, batch := range batches {
+		if len(batch) > MaxMapCells {
+			t.Fatalf("Batch of %d cells exceeds the limit", len(batch))
+		}
+		for _, id := range batch {
+			if seen[id] {
+				t.Fatal("Cell present in more than one batch")
+			}
+			seen[id] = true
+		}
+	}
+	if len(batches) < 2 {
+		t.Fatal("Expected the covering to be split")
+	}
+
+	if _, err = Cover(region, CoverOptions{MinLevel: 20, MaxLevel: 10}); err != ErrInvalidLevel {
+		t.Fatalf("Expected ErrInvalidLevel, got %v", err)
+	}
+}
+
+func TestCoverPolygon(t *testing.T) {
+	square := []LatLng{
+		{51.5007, -0.1246},
+		{51.5007, -0.1150},
+		{51.5055, -0.1150},
+		{51.5055, -0.1246},
+	}
+
+	// Both windings describe the same small area
+	for _, vertices := range [][]LatLng{square, {square[3], square[2], square[1], square[0]}} {
+		region, err := PolygonRegion(vertices)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cells, err := Cover(region, CoverOptions{MinLevel: 15, MaxLevel: 15})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(cells) == 0 || len(cells) > 20 {
+			t.Fatalf("Unexpected covering size %d", len(cells))
+		}
+	}
+
+	if _, err := PolygonRegion(square[:2]); err != ErrInvalidRegion {
+		t.Fatalf("Expected ErrInvalidRegion, got %v", err)
+	}
+}
@@ -0,0 +1,31 @@
+package helpers
+
+import (
+	"math"
+)
+
+// Bearing returns the initial course from a to b in degrees clockwise from
+// north, in the [0, 360) range
+func Bearing(a, b LatLng) float64 {
+	lat1, lat2 := a.Lat*math.Pi/180, b.Lat*math.Pi/180
+	dLng := (b.Lng - a.Lng) * math.Pi / 180
+	y := math.Sin(dLng) * math.Cos(lat2)
+	x := math.Cos(lat1)*math.Sin(lat2) - math.Sin(lat1)*math.Cos(lat2)*math.Cos(dLng)
+	return math.Mod(math.Atan2(y, x)*180/math.Pi+360, 360)
+}
+
+// Destination returns the point reached walking distance meters from a
+// following the bearing in degrees
+func Destination(a LatLng, bearing, distance float64) LatLng {
+	lat1, lng1 := a.Lat*math.Pi/180, a.Lng*math.Pi/180
+	brng := bearing * math.Pi / 180
+	d := distance / earthRadiusMeters
+
+	lat2 := math.Asin(math.Sin(lat1)*math.Cos(d) + math.Cos(lat1)*math.Sin(d)*math.Cos(brng))
+	lng2 := lng1 + math.Atan2(math.Sin(brng)*math.Sin(d)*math.Cos(lat1), math.Cos(d)-math.Sin(lat1)*math.Sin(lat2))
+
+	return LatLng{
+		Lat: lat2 * 180 / math.Pi,
+		Lng: math.Mod(lng2*180/math.Pi+540, 360) - 180,
+	}
+}
@@ -20,27 +20,26 @@ func (a AscID) Len() int           { return len(a) }
 func (a AscID) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
 func (a AscID) Less(i, j int) bool { return a[i] < a[j] }
 
+// GetCellsFromRadius returns the cells of the given level around a point. The
+// radius is capped to 1500 meters and only the MaxMapCells cells closest to the
+// point are kept.
+//
+// Deprecated: use Cover or CoverBatches, which report oversized coverings.
 func GetCellsFromRadius(lat, lng, radius float64, level int) []uint64 {
 	if radius > 1500 {
 		radius = 1500
 	}
 
-	cap := s2.CapFromCenterAngle(s2.PointFromLatLng(s2.LatLngFromDegrees(lat, lng)), kmToAngle(radius/1000))
+	center := s2.PointFromLatLng(s2.LatLngFromDegrees(lat, lng))
+	cells := cover(CapRegion(lat, lng, radius), CoverOptions{MinLevel: level, MaxLevel: level}, MaxMapCells)
 
-	rc := &s2.RegionCoverer{MaxLevel: level, MinLevel: level}
-	r := s2.Region(cap)
-	covering := rc.Covering(r)
-
-	var cells []uint64
-	for _, id := range covering {
-		cells = append(cells, uint64(id))
-	}
-
-	if len(cells) > 100 {
-		cells = cells[:100]
+	if len(cells) > MaxMapCells {
+		sort.Slice(cells, func(i, j int) bool {
+			return s2.CellID(cells[i]).Point().Distance(center) < s2.CellID(cells[j]).Point().Distance(center)
+		})
+		cells = cells[:MaxMapCells]
+		sort.Sort(AscID(cells))
 	}
 
-	sort.Sort(AscID(cells))
-
 	return cells
 }
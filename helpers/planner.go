@@ -0,0 +1,263 @@
+package helpers
+
+import (
+	"math"
+
+	"github.com/globalpokecache/POGOProtos-go"
+)
+
+// LatLng is a coordinate in degrees
+type LatLng struct {
+	Lat, Lng float64
+}
+
+const earthRadiusMeters = earthRadiusKm * 1000
+
+// Used when the server did not send map settings yet
+const (
+	defaultVisibleRange   = 70
+	defaultEncounterRange = 50
+)
+
+// StepRadius returns the radius a single step covers. Scanning only needs the
+// pokemon visible range, encountering needs every spawn inside the encounter
+// range too, so the smaller of both is used.
+func StepRadius(settings protos.MapSettings, encounter bool) float64 {
+	r := settings.PokemonVisibleRange
+	if r <= 0 {
+		r = defaultVisibleRange
+	}
+	if encounter {
+		er := settings.EncounterRangeMeters
+		if er <= 0 {
+			er = defaultEncounterRange
+		}
+		r = math.Min(r, er)
+	}
+	return r
+}
+
+// Distance returns the great circle distance between two points in meters
+func Distance(a, b LatLng) float64 {
+	lat1, lat2 := a.Lat*math.Pi/180, b.Lat*math.Pi/180
+	dLat := lat2 - lat1
+	dLng := (b.Lng - a.Lng) * math.Pi / 180
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLng/2)*math.Sin(dLng/2)
+	return 2 * earthRadiusMeters * math.Asin(math.Min(1, math.Sqrt(h)))
+}
+
+// projection is a local equirectangular projection in meters around an origin,
+// precise enough for the few kilometers a scan area spans
+type projection struct {
+	origin LatLng
+	cosLat float64
+}
+
+func newProjection(origin LatLng) projection {
+	return projection{origin, math.Cos(origin.Lat * math.Pi / 180)}
+}
+
+func (p projection) toXY(l LatLng) (float64, float64) {
+	x := (l.Lng - p.origin.Lng) * math.Pi / 180 * earthRadiusMeters * p.cosLat
+	y := (l.Lat - p.origin.Lat) * math.Pi / 180 * earthRadiusMeters
+	return x, y
+}
+
+func (p projection) toLatLng(x, y float64) LatLng {
+	return LatLng{
+		Lat: p.origin.Lat + y/earthRadiusMeters*180/math.Pi,
+		Lng: p.origin.Lng + x/(earthRadiusMeters*p.cosLat)*180/math.Pi,
+	}
+}
+
+// hexDirections walks the six sides of a hexagonal ring of a pointy lattice
+var hexDirections = [6][2]float64{
+	{-0.5, math.Sqrt(3) / 2},
+	{-1, 0},
+	{-0.5, -math.Sqrt(3) / 2},
+	{0.5, -math.Sqrt(3) / 2},
+	{1, 0},
+	{0.5, math.Sqrt(3) / 2},
+}
+
+// PlanRadius returns the steps of a beehive covering the circle of radius
+// meters around lat, lng. The route starts at the center and is ordered like
+// PlanPolygon to keep the walking distance short.
+func PlanRadius(lat, lng, radius, stepRadius float64) []LatLng {
+	center := LatLng{lat, lng}
+	if stepRadius <= 0 || radius <= 0 {
+		return []LatLng{center}
+	}
+
+	proj := newProjection(center)
+	spacing := math.Sqrt(3) * stepRadius
+	rings := int(math.Ceil(2 * radius / (3 * stepRadius)))
+
+	steps := []LatLng{center}
+	for ring := 1; ring <= rings; ring++ {
+		x, y := float64(ring)*spacing, 0.0
+		for _, dir := range hexDirections {
+			for i := 0; i < ring; i++ {
+				if math.Hypot(x, y) < radius+stepRadius {
+					steps = append(steps, proj.toLatLng(x, y))
+				}
+				x += dir[0] * spacing
+				y += dir[1] * spacing
+			}
+		}
+	}
+
+	return OrderRoute(center, steps)
+}
+
+// PlanPolygon returns the steps of a beehive covering the polygon, ordered to
+// keep the walking distance short. The polygon is a list of vertices in any
+// winding, closing the ring is optional.
+func PlanPolygon(polygon []LatLng, stepRadius float64) []LatLng {
+	if len(polygon) == 0 {
+		return nil
+	}
+	if len(polygon) < 3 || stepRadius <= 0 {
+		return []LatLng{polygon[0]}
+	}
+
+	proj := newProjection(polygon[0])
+	xs := make([]float64, len(polygon))
+	ys := make([]float64, len(polygon))
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for i, v := range polygon {
+		xs[i], ys[i] = proj.toXY(v)
+		minX, maxX = math.Min(minX, xs[i]), math.Max(maxX, xs[i])
+		minY, maxY = math.Min(minY, ys[i]), math.Max(maxY, ys[i])
+	}
+
+	dx := math.Sqrt(3) * stepRadius
+	dy := 1.5 * stepRadius
+
+	var steps []LatLng
+	for row := 0; minY+float64(row)*dy <= maxY+dy; row++ {
+		y := minY + float64(row)*dy
+		offset := 0.0
+		if row%2 == 1 {
+			offset = dx / 2
+		}
+		for x := minX - offset; x <= maxX+dx; x += dx {
+			if pointInPolygon(x, y, xs, ys) || distanceToPolygon(x, y, xs, ys) < stepRadius {
+				steps = append(steps, proj.toLatLng(x, y))
+			}
+		}
+	}
+
+	if len(steps) == 0 {
+		return []LatLng{polygon[0]}
+	}
+
+	return OrderRoute(steps[0], steps)
+}
+
+func pointInPolygon(x, y float64, xs, ys []float64) bool {
+	inside := false
+	for i, j := 0, len(xs)-1; i < len(xs); j, i = i, i+1 {
+		if (ys[i] > y) != (ys[j] > y) &&
+			x < (xs[j]-xs[i])*(y-ys[i])/(ys[j]-ys[i])+xs[i] {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+func distanceToPolygon(x, y float64, xs, ys []float64) float64 {
+	d := math.Inf(1)
+	for i, j := 0, len(xs)-1; i < len(xs); j, i = i, i+1 {
+		d = math.Min(d, distanceToSegment(x, y, xs[j], ys[j], xs[i], ys[i]))
+	}
+	return d
+}
+
+func distanceToSegment(x, y, x1, y1, x2, y2 float64) float64 {
+	vx, vy := x2-x1, y2-y1
+	l := vx*vx + vy*vy
+	if l == 0 {
+		return math.Hypot(x-x1, y-y1)
+	}
+	t := math.Max(0, math.Min(1, ((x-x1)*vx+(y-y1)*vy)/l))
+	return math.Hypot(x-(x1+t*vx), y-(y1+t*vy))
+}
+
+// OrderRoute orders the steps into a short open path beginning at the step
+// closest to start. It builds a nearest neighbour tour and improves it with
+// 2-opt until no exchange shortens it.
+func OrderRoute(start LatLng, steps []LatLng) []LatLng {
+	if len(steps) < 2 {
+		return append([]LatLng{}, steps...)
+	}
+
+	proj := newProjection(start)
+	xs := make([]float64, len(steps))
+	ys := make([]float64, len(steps))
+	for i, s := range steps {
+		xs[i], ys[i] = proj.toXY(s)
+	}
+	dist := func(a, b int) float64 {
+		return math.Hypot(xs[a]-xs[b], ys[a]-ys[b])
+	}
+
+	first := 0
+	for i := range steps {
+		if math.Hypot(xs[i], ys[i]) < math.Hypot(xs[first], ys[first]) {
+			first = i
+		}
+	}
+
+	visited := make([]bool, len(steps))
+	route := []int{first}
+	visited[first] = true
+	for len(route) < len(steps) {
+		last := route[len(route)-1]
+		next := -1
+		for i := range steps {
+			if !visited[i] && (next == -1 || dist(last, i) < dist(last, next)) {
+				next = i
+			}
+		}
+		visited[next] = true
+		route = append(route, next)
+	}
+
+	// The first step stays fixed, the path end is free
+	for improved := true; improved; {
+		improved = false
+		for i := 1; i < len(route)-1; i++ {
+			for j := i + 1; j < len(route); j++ {
+				before := dist(route[i-1], route[i])
+				after := dist(route[i-1], route[j])
+				if j+1 < len(route) {
+					before += dist(route[j], route[j+1])
+					after += dist(route[i], route[j+1])
+				}
+				if after < before-1e-9 {
+					for l, r := i, j; l < r; l, r = l+1, r-1 {
+						route[l], route[r] = route[r], route[l]
+					}
+					improved = true
+				}
+			}
+		}
+	}
+
+	ordered := make([]LatLng, len(route))
+	for i, idx := range route {
+		ordered[i] = steps[idx]
+	}
+	return ordered
+}
+
+// RouteDistance returns the walking distance along the steps in meters
+func RouteDistance(steps []LatLng) float64 {
+	var d float64
+	for i := 1; i < len(steps); i++ {
+		d += Distance(steps[i-1], steps[i])
+	}
+	return d
+}
@@ -0,0 +1,98 @@
+package helpers
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func closestStep(p LatLng, steps []LatLng) float64 {
+	d := math.Inf(1)
+	for _, s := range steps {
+		d = math.Min(d, Distance(p, s))
+	}
+	return d
+}
+
+func TestPlanRadiusCoverage(t *testing.T) {
+	center := LatLng{40.7829, -73.9654}
+	steps := PlanRadius(center.Lat, center.Lng, 500, 70)
+
+	if steps[0] != center {
+		t.Fatal("Expected the route to start at the center")
+	}
+
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 2000; i++ {
+		angle := r.Float64() * 2 * math.Pi
+		dist := math.Sqrt(r.Float64()) * 500
+		p := newProjection(center).toLatLng(dist*math.Cos(angle), dist*math.Sin(angle))
+		if d := closestStep(p, steps); d > 70.5 {
+			t.Fatalf("Point %v is %.1fm away from the closest step", p, d)
+		}
+	}
+
+	for i := 1; i < len(steps); i++ {
+		if Distance(center, steps[i]) > 570 {
+			t.Fatalf("Step %d does not overlap the area", i)
+		}
+	}
+}
+
+func TestPlanPolygon(t *testing.T) {
+	polygon := []LatLng{
+		{51.5007, -0.1246},
+		{51.5055, -0.1246},
+		{51.5055, -0.1150},
+		{51.5007, -0.1150},
+	}
+	steps := PlanPolygon(polygon, 70)
+	if len(steps) == 0 {
+		t.Fatal("Expected steps inside the polygon")
+	}
+
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 2000; i++ {
+		p := LatLng{
+			Lat: 51.5007 + r.Float64()*(51.5055-51.5007),
+			Lng: -0.1246 + r.Float64()*(-0.1150+0.1246),
+		}
+		if d := closestStep(p, steps); d > 70.5 {
+			t.Fatalf("Point %v is %.1fm away from the closest step", p, d)
+		}
+	}
+}
+
+func TestOrderRoute(t *testing.T) {
+	// On a 4x5 grid the shortest open path is a serpentine visiting each
+	// point once with only grid steps
+	origin := LatLng{48.8566, 2.3522}
+	proj := newProjection(origin)
+	const spacing = 100.0
+	var grid []LatLng
+	for i := 0; i < 4; i++ {
+		for j := 0; j < 5; j++ {
+			grid = append(grid, proj.toLatLng(float64(j)*spacing, float64(i)*spacing))
+		}
+	}
+	r := rand.New(rand.NewSource(1))
+	r.Shuffle(len(grid), func(i, j int) {
+		grid[i], grid[j] = grid[j], grid[i]
+	})
+
+	route := OrderRoute(origin, grid)
+	if route[0] != origin {
+		t.Fatal("Expected the route to start at the closest step")
+	}
+	optimal := float64(len(grid)-1) * spacing
+	if d := RouteDistance(route); math.Abs(d-optimal) > 1 {
+		t.Fatalf("Route is %.1fm long, the optimal one is %.1fm", d, optimal)
+	}
+
+	// The beehive of PlanRadius is a lattice too
+	steps := PlanRadius(origin.Lat, origin.Lng, 500, 70)
+	optimal = float64(len(steps)-1) * math.Sqrt(3) * 70
+	if d := RouteDistance(steps); d > optimal*1.1 {
+		t.Fatalf("Radius route is %.1fm long, the optimal one is %.1fm", d, optimal)
+	}
+}
@@ -0,0 +1,439 @@
+package mapdiff
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/globalpokecache/POGOProtos-go"
+)
+
+// EventType identifies the kind of change reported between two scans
+type EventType int
+
+const (
+	PokemonAppeared EventType = iota
+	PokemonDespawned
+	FortTeamChanged
+	LureAdded
+	LureExpired
+	GymPointsChanged
+	SpawnPointDiscovered
+)
+
+var eventTypeNames = map[EventType]string{
+	PokemonAppeared:      "POKEMON_APPEARED",
+	PokemonDespawned:     "POKEMON_DESPAWNED",
+	FortTeamChanged:      "FORT_TEAM_CHANGED",
+	LureAdded:            "LURE_ADDED",
+	LureExpired:          "LURE_EXPIRED",
+	GymPointsChanged:     "GYM_POINTS_CHANGED",
+	SpawnPointDiscovered: "SPAWN_POINT_DISCOVERED",
+}
+
+func (t EventType) String() string {
+	if name, ok := eventTypeNames[t]; ok {
+		return name
+	}
+	return "UNKNOWN"
+}
+
+// Pokemon is the tracked state of a single encounter
+type Pokemon struct {
+	EncounterID  uint64
+	PokemonID    protos.PokemonId
+	SpawnPointID string
+	Latitude     float64
+	Longitude    float64
+	// Expiration is zero when the server did not report a despawn time
+	Expiration time.Time
+}
+
+// SpawnPoint is a spawn location reported by a map cell
+type SpawnPoint struct {
+	Latitude  float64
+	Longitude float64
+}
+
+// Event describes one change found between two scans. Only the fields
+// relevant to Type are set; Fort and PreviousFort are copies and safe to keep.
+type Event struct {
+	Seq          uint64
+	Type         EventType
+	CellID       uint64
+	Time         time.Time
+	Pokemon      *Pokemon
+	Fort         *protos.FortData
+	PreviousFort *protos.FortData
+	SpawnPoint   *SpawnPoint
+}
+
+var ErrClosed = errors.New("Differ is closed")
+
+type trackedPokemon struct {
+	Pokemon
+	cellID uint64
+}
+
+// Differ keeps the state seen on previous scans and emits the changes found on
+// every new GetMapObjectsResponse to its subscribers
+type Differ struct {
+	mu          sync.Mutex
+	deliverMu   sync.Mutex
+	seq         uint64
+	closed      bool
+	pokemons    map[uint64]*trackedPokemon
+	forts       map[string]*protos.FortData
+	fortCells   map[string]uint64
+	spawnPoints map[SpawnPoint]bool
+	subs        []*Subscription
+}
+
+// New creates an empty Differ, the first update reports everything it sees
+func New() *Differ {
+	return &Differ{
+		pokemons:    map[uint64]*trackedPokemon{},
+		forts:       map[string]*protos.FortData{},
+		fortCells:   map[string]uint64{},
+		spawnPoints: map[SpawnPoint]bool{},
+	}
+}
+
+// Subscription receives the events of a Differ in order. Events are not
+// dropped: a full buffer blocks Update until the subscriber catches up.
+type Subscription struct {
+	d      *Differ
+	events chan Event
+	done   chan struct{}
+	once   sync.Once
+}
+
+// Subscribe registers a new subscription with room for buffer pending events
+func (d *Differ) Subscribe(buffer int) *Subscription {
+	if buffer < 0 {
+		buffer = 0
+	}
+	s := &Subscription{
+		d:      d,
+		events: make(chan Event, buffer),
+		done:   make(chan struct{}),
+	}
+
+	d.mu.Lock()
+	if d.closed {
+		close(s.events)
+	} else {
+		d.subs = append(d.subs, s)
+	}
+	d.mu.Unlock()
+
+	return s
+}
+
+// Events returns the channel the subscription is fed from. It is closed when
+// either the subscription or the Differ is closed.
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+// Close stops the subscription, pending updates no longer wait for it
+func (s *Subscription) Close() {
+	s.once.Do(func() {
+		close(s.done)
+		s.d.unsubscribe(s)
+	})
+}
+
+func (d *Differ) unsubscribe(s *Subscription) {
+	d.deliverMu.Lock()
+	defer d.deliverMu.Unlock()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for i, sub := range d.subs {
+		if sub == s {
+			d.subs = append(d.subs[:i], d.subs[i+1:]...)
+			close(s.events)
+			return
+		}
+	}
+}
+
+// Close ends every subscription, further updates fail with ErrClosed
+func (d *Differ) Close() {
+	d.mu.Lock()
+	if d.closed {
+		d.mu.Unlock()
+		return
+	}
+	d.closed = true
+	subs := d.subs
+	d.subs = nil
+	d.mu.Unlock()
+
+	for _, s := range subs {
+		s.once.Do(func() {
+			close(s.done)
+		})
+	}
+
+	d.deliverMu.Lock()
+	for _, s := range subs {
+		close(s.events)
+	}
+	d.deliverMu.Unlock()
+}
+
+// Update compares a new scan with the known state and delivers the resulting
+// events to all subscribers. It blocks until every subscriber accepted them or
+// ctx is done; the state is updated either way. The events are also returned.
+func (d *Differ) Update(ctx context.Context, resp *protos.GetMapObjectsResponse) ([]Event, error) {
+	if resp == nil {
+		return nil, nil
+	}
+
+	d.deliverMu.Lock()
+	defer d.deliverMu.Unlock()
+
+	d.mu.Lock()
+	if d.closed {
+		d.mu.Unlock()
+		return nil, ErrClosed
+	}
+	events := d.diff(resp)
+	subs := append([]*Subscription{}, d.subs...)
+	d.mu.Unlock()
+
+	for _, ev := range events {
+		for _, s := range subs {
+			select {
+			case s.events <- ev:
+			case <-s.done:
+			case <-ctx.Done():
+				return events, ctx.Err()
+			}
+		}
+	}
+
+	return events, nil
+}
+
+func scanTime(resp *protos.GetMapObjectsResponse) time.Time {
+	var ms int64
+	for _, cell := range resp.MapCells {
+		if cell.CurrentTimestampMs > ms {
+			ms = cell.CurrentTimestampMs
+		}
+	}
+	if ms == 0 {
+		return time.Now()
+	}
+	return time.Unix(0, ms*int64(time.Millisecond))
+}
+
+func msToTime(ms int64) time.Time {
+	if ms <= 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, ms*int64(time.Millisecond))
+}
+
+func copyFort(f *protos.FortData) *protos.FortData {
+	c := *f
+	if f.LureInfo != nil {
+		lure := *f.LureInfo
+		c.LureInfo = &lure
+	}
+	return &c
+}
+
+func lureActive(f *protos.FortData, now time.Time) bool {
+	if f.LureInfo == nil {
+		return false
+	}
+	return f.LureInfo.LureExpiresTimestampMs == 0 || msToTime(f.LureInfo.LureExpiresTimestampMs).After(now)
+}
+
+// diff must be called with d.mu held
+func (d *Differ) diff(resp *protos.GetMapObjectsResponse) []Event {
+	now := scanTime(resp)
+
+	cells := append([]*protos.MapCell{}, resp.MapCells...)
+	sort.Slice(cells, func(i, j int) bool {
+		return cells[i].S2CellId < cells[j].S2CellId
+	})
+
+	var events []Event
+	emit := func(ev Event) {
+		d.seq++
+		ev.Seq = d.seq
+		ev.Time = now
+		events = append(events, ev)
+	}
+
+	scannedForts := map[string]bool{}
+	scannedCells := map[uint64]bool{}
+	seen := map[uint64]bool{}
+	for _, cell := range cells {
+		scannedCells[cell.S2CellId] = true
+		for _, sp := range cell.SpawnPoints {
+			key := SpawnPoint{sp.Latitude, sp.Longitude}
+			if !d.spawnPoints[key] {
+				d.spawnPoints[key] = true
+				point := key
+				emit(Event{Type: SpawnPointDiscovered, CellID: cell.S2CellId, SpawnPoint: &point})
+			}
+		}
+
+		for _, p := range cellPokemons(cell) {
+			seen[p.EncounterID] = true
+			if known, ok := d.pokemons[p.EncounterID]; ok {
+				if p.Expiration.IsZero() {
+					p.Expiration = known.Expiration
+				}
+				known.Pokemon = p
+				known.cellID = cell.S2CellId
+				continue
+			}
+			d.pokemons[p.EncounterID] = &trackedPokemon{p, cell.S2CellId}
+			pokemon := p
+			emit(Event{Type: PokemonAppeared, CellID: cell.S2CellId, Pokemon: &pokemon})
+		}
+
+		forts := append([]*protos.FortData{}, cell.Forts...)
+		sort.Slice(forts, func(i, j int) bool {
+			return forts[i].Id < forts[j].Id
+		})
+		for _, f := range forts {
+			prev, ok := d.forts[f.Id]
+			cur := copyFort(f)
+			d.forts[f.Id] = cur
+			d.fortCells[f.Id] = cell.S2CellId
+			scannedForts[f.Id] = true
+			if !ok {
+				if lureActive(cur, now) {
+					emit(Event{Type: LureAdded, CellID: cell.S2CellId, Fort: cur})
+				}
+				continue
+			}
+
+			if prev.OwnedByTeam != cur.OwnedByTeam {
+				emit(Event{Type: FortTeamChanged, CellID: cell.S2CellId, Fort: cur, PreviousFort: prev})
+			}
+			if cur.Type == protos.FortType_GYM && prev.GymPoints != cur.GymPoints {
+				emit(Event{Type: GymPointsChanged, CellID: cell.S2CellId, Fort: cur, PreviousFort: prev})
+			}
+
+			wasLured, isLured := lureActive(prev, now), lureActive(cur, now)
+			if !wasLured && isLured {
+				emit(Event{Type: LureAdded, CellID: cell.S2CellId, Fort: cur, PreviousFort: prev})
+			} else if wasLured && !isLured {
+				emit(Event{Type: LureExpired, CellID: cell.S2CellId, Fort: cur, PreviousFort: prev})
+			}
+		}
+	}
+
+	// Lures also run out on forts that were not part of this scan
+	var fortIDs []string
+	for id := range d.forts {
+		fortIDs = append(fortIDs, id)
+	}
+	sort.Strings(fortIDs)
+	for _, id := range fortIDs {
+		f := d.forts[id]
+		if f.LureInfo == nil || f.LureInfo.LureExpiresTimestampMs == 0 {
+			continue
+		}
+		if !msToTime(f.LureInfo.LureExpiresTimestampMs).After(now) {
+			prev := f
+			cur := copyFort(f)
+			cur.LureInfo = nil
+			d.forts[id] = cur
+			if !scannedForts[id] {
+				emit(Event{Type: LureExpired, CellID: d.fortCells[id], Fort: cur, PreviousFort: prev})
+			}
+		}
+	}
+
+	// A missing pokemon is kept until its despawn time if the server told it,
+	// it may just be out of the visible range. Without one it is dropped once
+	// its cell comes back without it, scans of other cells tell nothing.
+	var gone []uint64
+	for id, p := range d.pokemons {
+		if seen[id] {
+			continue
+		}
+		if p.Expiration.IsZero() {
+			if scannedCells[p.cellID] {
+				gone = append(gone, id)
+			}
+		} else if !p.Expiration.After(now) {
+			gone = append(gone, id)
+		}
+	}
+	sort.Slice(gone, func(i, j int) bool {
+		return gone[i] < gone[j]
+	})
+	for _, id := range gone {
+		p := d.pokemons[id]
+		delete(d.pokemons, id)
+		pokemon := p.Pokemon
+		emit(Event{Type: PokemonDespawned, CellID: p.cellID, Pokemon: &pokemon})
+	}
+
+	return events
+}
+
+func cellPokemons(cell *protos.MapCell) []Pokemon {
+	byID := map[uint64]Pokemon{}
+	var order []uint64
+
+	for _, w := range cell.WildPokemons {
+		p := Pokemon{
+			EncounterID:  w.EncounterId,
+			SpawnPointID: w.SpawnPointId,
+			Latitude:     w.Latitude,
+			Longitude:    w.Longitude,
+		}
+		if w.PokemonData != nil {
+			p.PokemonID = w.PokemonData.PokemonId
+		}
+		if w.TimeTillHiddenMs > 0 && w.LastModifiedTimestampMs > 0 {
+			p.Expiration = msToTime(w.LastModifiedTimestampMs + int64(w.TimeTillHiddenMs))
+		}
+		if _, ok := byID[p.EncounterID]; !ok {
+			order = append(order, p.EncounterID)
+		}
+		byID[p.EncounterID] = p
+	}
+
+	for _, m := range cell.CatchablePokemons {
+		p, ok := byID[m.EncounterId]
+		if !ok {
+			order = append(order, m.EncounterId)
+			p = Pokemon{
+				EncounterID:  m.EncounterId,
+				SpawnPointID: m.SpawnPointId,
+				Latitude:     m.Latitude,
+				Longitude:    m.Longitude,
+			}
+		}
+		p.PokemonID = m.PokemonId
+		if m.ExpirationTimestampMs > 0 {
+			p.Expiration = msToTime(m.ExpirationTimestampMs)
+		}
+		byID[m.EncounterId] = p
+	}
+
+	sort.Slice(order, func(i, j int) bool {
+		return order[i] < order[j]
+	})
+
+	pokemons := make([]Pokemon, 0, len(order))
+	for _, id := range order {
+		pokemons = append(pokemons, byID[id])
+	}
+	return pokemons
+}
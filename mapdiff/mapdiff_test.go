@@ -0,0 +1,164 @@
+package mapdiff
+
+import (
+	"context"
+	"testing"
+
+	"github.com/globalpokecache/POGOProtos-go"
+)
+
+func scan(ms int64, cell *protos.MapCell) *protos.GetMapObjectsResponse {
+	cell.CurrentTimestampMs = ms
+	return &protos.GetMapObjectsResponse{MapCells: []*protos.MapCell{cell}}
+}
+
+func TestDifferEvents(t *testing.T) {
+	ctx := context.Background()
+	d := New()
+	sub := d.Subscribe(16)
+
+	_, err := d.Update(ctx, scan(1000, &protos.MapCell{
+		S2CellId:    1,
+		SpawnPoints: []*protos.SpawnPoint{{Latitude: 1, Longitude: 1}},
+		Forts: []*protos.FortData{
+			{Id: "gym", Type: protos.FortType_GYM, OwnedByTeam: protos.TeamColor_BLUE, GymPoints: 100},
+			{Id: "stop", Type: protos.FortType_CHECKPOINT},
+		},
+		CatchablePokemons: []*protos.MapPokemon{
+			{EncounterId: 10, ExpirationTimestampMs: 5000},
+			{EncounterId: 11},
+		},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = d.Update(ctx, scan(2000, &protos.MapCell{
+		S2CellId:    1,
+		SpawnPoints: []*protos.SpawnPoint{{Latitude: 1, Longitude: 1}},
+		Forts: []*protos.FortData{
+			{Id: "gym", Type: protos.FortType_GYM, OwnedByTeam: protos.TeamColor_RED, GymPoints: 50},
+			{Id: "stop", Type: protos.FortType_CHECKPOINT, LureInfo: &protos.FortLureInfo{LureExpiresTimestampMs: 3000}},
+		},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = d.Update(ctx, scan(6000, &protos.MapCell{S2CellId: 2}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.Close()
+
+	expected := []EventType{
+		SpawnPointDiscovered, PokemonAppeared, PokemonAppeared,
+		FortTeamChanged, GymPointsChanged, LureAdded, PokemonDespawned,
+		LureExpired, PokemonDespawned,
+	}
+
+	var got []Event
+	for ev := range sub.Events() {
+		got = append(got, ev)
+	}
+
+	if len(got) != len(expected) {
+		t.Fatalf("Expected %d events, got %d: %v", len(expected), len(got), got)
+	}
+	for i, ev := range got {
+		if ev.Type != expected[i] {
+			t.Fatalf("Event %d: expected %s, got %s", i, expected[i], ev.Type)
+		}
+		if ev.Seq != uint64(i+1) {
+			t.Fatalf("Event %d: out of order sequence %d", i, ev.Seq)
+		}
+	}
+
+	if got[6].Pokemon.EncounterID != 11 || got[8].Pokemon.EncounterID != 10 {
+		t.Fatal("Unexpected despawn order")
+	}
+}
+
+func TestDifferBackpressure(t *testing.T) {
+	d := New()
+	d.Subscribe(0)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := d.Update(ctx, scan(1000, &protos.MapCell{
+		S2CellId:    1,
+		SpawnPoints: []*protos.SpawnPoint{{Latitude: 1, Longitude: 1}},
+	}))
+	if err != context.Canceled {
+		t.Fatalf("Expected update to block on a full subscriber, got %v", err)
+	}
+}
+
+func TestDifferFirstSight(t *testing.T) {
+	ctx := context.Background()
+	d := New()
+
+	events, err := d.Update(ctx, scan(1000, &protos.MapCell{
+		S2CellId: 1,
+		Forts: []*protos.FortData{
+			{Id: "stop", Type: protos.FortType_CHECKPOINT, LureInfo: &protos.FortLureInfo{LureExpiresTimestampMs: 5000}},
+		},
+		CatchablePokemons: []*protos.MapPokemon{{EncounterId: 10}},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 || events[0].Type != PokemonAppeared || events[1].Type != LureAdded || events[1].PreviousFort != nil {
+		t.Fatalf("Expected a lure on a new fort to be reported, got %v", events)
+	}
+
+	// The pokemon has no despawn time, a scan of another cell keeps it
+	events, err = d.Update(ctx, scan(2000, &protos.MapCell{S2CellId: 2}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 0 {
+		t.Fatalf("Expected the pokemon out of the scan to be kept, got %v", events)
+	}
+
+	events, err = d.Update(ctx, scan(3000, &protos.MapCell{S2CellId: 1}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].Type != PokemonDespawned || events[0].Pokemon.EncounterID != 10 {
+		t.Fatalf("Expected the pokemon missing from its cell to be dropped, got %v", events)
+	}
+}
+
+func TestDifferBatches(t *testing.T) {
+	ctx := context.Background()
+	d := New()
+
+	batches := []*protos.MapCell{
+		{S2CellId: 1, CatchablePokemons: []*protos.MapPokemon{{EncounterId: 10}}},
+		{S2CellId: 2, CatchablePokemons: []*protos.MapPokemon{{EncounterId: 20}}},
+	}
+	appeared := 0
+	for i := 0; i < 6; i++ {
+		cell := *batches[i%2]
+		events, err := d.Update(ctx, scan(int64(1000*(i+1)), &cell))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, ev := range events {
+			if ev.Type != PokemonAppeared {
+				t.Fatalf("Batch %d: unexpected %s event", i, ev.Type)
+			}
+			appeared++
+		}
+	}
+	if appeared != 2 {
+		t.Fatalf("Expected each pokemon to appear once, got %d events", appeared)
+	}
+
+	// An empty scan reports nothing either
+	if events, _ := d.Update(ctx, &protos.GetMapObjectsResponse{}); len(events) != 0 {
+		t.Fatalf("Empty scan gave %v", events)
+	}
+}
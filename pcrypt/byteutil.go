@@ -2,8 +2,7 @@ package pcrypt
 
 import (
 	"crypto/rand"
-	"reflect"
-	"unsafe"
+	"encoding/binary"
 )
 
 const SIZEOF_INT32 = 4 // bytes
@@ -12,12 +11,13 @@ func RandomBytes(n int) []byte {
 	rand.Read(bytes)
 	return bytes
 }
+
+// AsDwordSlice reads the bytes as little endian dwords, trailing bytes that
+// don't fill a dword are dropped
 func AsDwordSlice(bytes []byte) []uint32 {
-	// Get the slice header
-	header := *(*reflect.SliceHeader)(unsafe.Pointer(&bytes))
-	// The length and capacity of the slice are different.
-	header.Len /= SIZEOF_INT32
-	header.Cap /= SIZEOF_INT32
-	// Convert slice header to an []uint32
-	return *(*[]uint32)(unsafe.Pointer(&header))
+	dwords := make([]uint32, len(bytes)/SIZEOF_INT32)
+	for i := range dwords {
+		dwords[i] = binary.LittleEndian.Uint32(bytes[i*SIZEOF_INT32:])
+	}
+	return dwords
 }
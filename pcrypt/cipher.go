@@ -0,0 +1,164 @@
+package pcrypt
+
+import (
+	"crypto/cipher"
+	"encoding/binary"
+	"errors"
+
+	"golang.org/x/crypto/twofish"
+)
+
+// Cipher encrypts the signatures with the scheme of a game version
+type Cipher interface {
+	Encrypt(input []byte, msSinceStart uint32) ([]byte, error)
+	Decrypt(input []byte) ([]byte, error)
+}
+
+// Encryptor is a Cipher able to encrypt into a caller owned buffer, to sign
+// many requests without allocating
+type Encryptor interface {
+	Cipher
+	EncryptTo(dst, input []byte, msSinceStart uint32) []byte
+}
+
+// TwofishVersion is the first API version encrypting with Twofish
+const TwofishVersion = 4500
+
+// ErrLegacyScheme is returned for the versions before 0.45. They used the
+// obfuscated "unknown6" scheme, which was never published in a form this
+// package could implement.
+var ErrLegacyScheme = errors.New("Signature encryption before 0.45 is not supported")
+
+var (
+	ErrInvalidSize = errors.New("Invalid encrypted signature size")
+	ErrIntegrity   = errors.New("Encrypted signature integrity check failed")
+	ErrPadding     = errors.New("Invalid encrypted signature padding")
+)
+
+var defaultCipher, _ = NewTwofish(encKey, twofishIntegrity)
+
+// ForVersion returns the cipher of an API version with its key and integrity
+// byte
+func ForVersion(version int, key []byte, integrity byte) (Encryptor, error) {
+	if version < TwofishVersion {
+		return nil, ErrLegacyScheme
+	}
+	return NewTwofish(key, integrity)
+}
+
+// Twofish is the scheme of 0.45 and later: Twofish in CBC mode seeded with
+// the time since start, then a stream cipher pass
+type Twofish struct {
+	block     cipher.Block
+	integrity byte
+}
+
+func NewTwofish(key []byte, integrity byte) (*Twofish, error) {
+	block, err := twofish.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return &Twofish{block, integrity}, nil
+}
+
+func (t *Twofish) Encrypt(input []byte, msSinceStart uint32) ([]byte, error) {
+	return t.EncryptTo(nil, input, msSinceStart), nil
+}
+
+// EncryptTo appends the encrypted input to dst and returns the extended
+// buffer. It doesn't allocate when dst has enough capacity, dst and input
+// must not overlap.
+func (t *Twofish) EncryptTo(dst, input []byte, msSinceStart uint32) []byte {
+	rand := &cRand{msSinceStart}
+	iv := makeIv(rand)
+
+	inputlen := len(input)
+	blockCount := (inputlen + 256) / 256
+
+	outputSize := (blockCount * 256) + 5
+	dst, output := grow(dst, outputSize)
+
+	binary.BigEndian.PutUint32(output, msSinceStart)
+
+	copy(output[4:], input)
+	// dst may hold old data
+	for i := 4 + inputlen; i < outputSize; i++ {
+		output[i] = 0
+	}
+	output[4+inputlen] = byte(256 - inputlen%256)
+	output[outputSize-2] = byte(256 - inputlen%256)
+
+	for offset := 4; offset < blockCount*256; offset += twofish.BlockSize {
+		for i := 0; i < twofish.BlockSize; i++ {
+			output[offset+i] ^= iv[i]
+		}
+		t.block.Encrypt(output[offset:], output[offset:])
+		copy(iv[:], output[offset:offset+twofish.BlockSize])
+	}
+
+	output[outputSize-1] = t.integrity
+	encryptCipher(output, outputSize)
+
+	return dst
+}
+
+// grow extends dst by n bytes and returns it with the added part
+func grow(dst []byte, n int) ([]byte, []byte) {
+	l := len(dst)
+	if cap(dst)-l < n {
+		buf := make([]byte, l, l+n)
+		copy(buf, dst)
+		dst = buf
+	}
+	dst = dst[:l+n]
+	return dst, dst[l:]
+}
+
+// Decrypt inverts Encrypt. The buffer is left untouched.
+func (t *Twofish) Decrypt(buffer []byte) ([]byte, error) {
+	size := len(buffer)
+	if size < 256+5 || (size-5)%256 != 0 {
+		return nil, ErrInvalidSize
+	}
+
+	// The stream pass only depends on the size, applying it again undoes it
+	data := make([]byte, size)
+	copy(data, buffer)
+	encryptCipher(data, size)
+
+	if data[size-1] != t.integrity {
+		return nil, ErrIntegrity
+	}
+
+	rand := &cRand{binary.BigEndian.Uint32(data[0:4])}
+	iv := makeIv(rand)
+
+	plain := data[4 : size-1]
+	var block [twofish.BlockSize]byte
+	for offset := 0; offset < len(plain); offset += twofish.BlockSize {
+		t.block.Decrypt(block[:], plain[offset:])
+		for i := 0; i < twofish.BlockSize; i++ {
+			block[i] ^= iv[i]
+			iv[i] = plain[offset+i]
+		}
+		copy(plain[offset:], block[:])
+	}
+
+	// The byte after the input and the last one hold the padding length, the
+	// ones between are zeros
+	pad := int(plain[len(plain)-1])
+	if pad == 0 {
+		pad = 256
+	}
+	inputlen := len(plain) - pad
+	if plain[inputlen] != plain[len(plain)-1] {
+		return nil, ErrPadding
+	}
+	for i := inputlen + 1; i < len(plain)-1; i++ {
+		if plain[i] != 0 {
+			return nil, ErrPadding
+		}
+	}
+
+	return plain[:inputlen], nil
+}
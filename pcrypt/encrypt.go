@@ -1,8 +1,6 @@
 package pcrypt
 
 import (
-	"encoding/binary"
-
 	"golang.org/x/crypto/twofish"
 )
 
@@ -33,10 +31,11 @@ var (
 		0x33, 0xff, 0x58, 0x18, 0x93, 0x46, 0xc8, 0xdf, 0x3c, 0xfb, 0x8d, 0xb1, 0x55, 0xd5, 0x6f, 0x70,
 		0xef, 0x9d, 0xa1, 0x9e, 0xb6, 0xea, 0xc6, 0xf1, 0x80, 0x1d, 0x05, 0x73, 0xd6, 0xb3, 0x36, 0x85,
 	}
-
-	IntegrityByte byte = 0x23
 )
 
+// Integrity byte of the Twofish scheme
+const twofishIntegrity byte = 0x23
+
 type cRand struct {
 	state uint32
 }
@@ -46,8 +45,8 @@ func (rand *cRand) rand() byte {
 	return byte((rand.state >> 16) & 0x7FFF)
 }
 
-func makeIv(rand *cRand) []byte {
-	iv := make([]byte, twofish.BlockSize)
+func makeIv(rand *cRand) [twofish.BlockSize]byte {
+	var iv [twofish.BlockSize]byte
 	for i := 0; i < len(iv); i++ {
 		iv[i] = rand.rand()
 	}
@@ -55,8 +54,8 @@ func makeIv(rand *cRand) []byte {
 }
 
 func encryptCipher(src []byte, size int) {
-	nxbox := make([]byte, 256)
-	copy(nxbox, xbox)
+	var nxbox [256]byte
+	copy(nxbox[:], xbox)
 
 	a4 := size - 1
 	srci := 0
@@ -77,33 +76,6 @@ func encryptCipher(src []byte, size int) {
 }
 
 func Encrypt(input []byte, msSinceStart uint32) []byte {
-	tf, _ := twofish.NewCipher(encKey)
-
-	rand := &cRand{msSinceStart}
-	iv := makeIv(rand)
-
-	inputlen := len(input)
-	blockCount := (inputlen + 256) / 256
-
-	outputSize := (blockCount * 256) + 5
-	output := make([]byte, outputSize)
-
-	binary.BigEndian.PutUint32(output, msSinceStart)
-
-	copy(output[4:], input)
-	output[4+inputlen] = byte(256 - inputlen%256)
-	output[outputSize-2] = byte(256 - inputlen%256)
-
-	for offset := 4; offset < blockCount*256; offset += twofish.BlockSize {
-		for i := 0; i < twofish.BlockSize; i++ {
-			output[offset+i] ^= iv[i]
-		}
-		tf.Encrypt(output[offset:], output[offset:])
-		copy(iv, output[offset:offset+twofish.BlockSize])
-	}
-
-	output[outputSize-1] = IntegrityByte
-	encryptCipher(output, outputSize)
-
+	output, _ := defaultCipher.Encrypt(input, msSinceStart)
 	return output
 }
@@ -1,26 +1,113 @@
 package pcrypt
 
 import (
+	"bytes"
 	"crypto/rand"
-	"fmt"
 	"testing"
 	"time"
 )
 
 func TestEncrypt(t *testing.T) {
-	input := make([]byte, 32)
+	ms := uint32(time.Now().Unix())
+
+	for _, size := range []int{0, 1, 32, 255, 256, 257, 1000} {
+		input := make([]byte, size)
+		rand.Read(input)
+
+		result := Encrypt(input, ms)
+		encrypted := append([]byte{}, result...)
+
+		dec, err := Decrypt(result)
+		if err != nil {
+			t.Fatalf("Failed to decrypt %d bytes: %s", size, err)
+		}
+		if !bytes.Equal(input, dec) {
+			t.Fatalf("Encrypted input of %d bytes is different from decrypted output", size)
+		}
+		if !bytes.Equal(result, encrypted) {
+			t.Fatal("Decrypt modified its input")
+		}
+	}
+}
+
+func TestDecryptInvalid(t *testing.T) {
+	if _, err := Decrypt([]byte{1, 2, 3}); err != ErrInvalidSize {
+		t.Fatalf("Expected ErrInvalidSize, got %v", err)
+	}
+
+	result := Encrypt([]byte("signature"), 1234)
+	result[len(result)-1] ^= 0xff
+	if _, err := Decrypt(result); err != ErrIntegrity {
+		t.Fatalf("Expected ErrIntegrity, got %v", err)
+	}
+
+	result = Encrypt([]byte("signature"), 1234)
+	result[10] ^= 0xff
+	if _, err := Decrypt(result); err != ErrPadding {
+		t.Fatalf("Expected ErrPadding, got %v", err)
+	}
+}
+
+func TestForVersion(t *testing.T) {
+	if _, err := ForVersion(4300, encKey, twofishIntegrity); err != ErrLegacyScheme {
+		t.Fatalf("Expected ErrLegacyScheme, got %v", err)
+	}
+
+	c, err := ForVersion(5500, encKey, twofishIntegrity)
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := []byte("signature")
+	output, err := c.Encrypt(input, 1234)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(output, Encrypt(input, 1234)) {
+		t.Fatal("Cipher output differs from Encrypt")
+	}
+}
+
+func TestEncryptTo(t *testing.T) {
+	c, err := NewTwofish(encKey, twofishIntegrity)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	input := make([]byte, 300)
 	rand.Read(input)
 
-	ms := uint32(time.Now().Unix())
-	// fmt.Println(input, ms)
+	// Reused buffers must not leak old data into the padding
+	buf := bytes.Repeat([]byte{0xff}, 1024)
+	buf = c.EncryptTo(buf[:3], input, 1234)
+	if !bytes.Equal(buf[3:], Encrypt(input, 1234)) {
+		t.Fatal("EncryptTo output differs from Encrypt")
+	}
 
-	result := Encrypt(input, ms)
-	fmt.Println(result)
+	allocs := testing.AllocsPerRun(100, func() {
+		buf = c.EncryptTo(buf[:0], input, 1234)
+	})
+	if allocs != 0 {
+		t.Fatalf("EncryptTo allocated %v times", allocs)
+	}
+}
 
-	// dec := Decrypt(result)
-	// fmt.Println(dec)
+func BenchmarkEncrypt(b *testing.B) {
+	input := make([]byte, 600)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		Encrypt(input, uint32(i))
+	}
+}
 
-	// if bytes.Compare(input, dec) != 0 {
-	// 	t.Fatal("Encrypted input is different from decrypted output")
-	// }
+func BenchmarkEncryptTo(b *testing.B) {
+	c, err := NewTwofish(encKey, twofishIntegrity)
+	if err != nil {
+		b.Fatal(err)
+	}
+	input := make([]byte, 600)
+	var buf []byte
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		buf = c.EncryptTo(buf[:0], input, uint32(i))
+	}
 }
@@ -0,0 +1,115 @@
+package route
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+type geoJSON struct {
+	Type        string          `json:"type"`
+	Features    []geoJSON       `json:"features"`
+	Geometry    *geoJSON        `json:"geometry"`
+	Geometries  []geoJSON       `json:"geometries"`
+	Coordinates json.RawMessage `json:"coordinates"`
+	Properties  struct {
+		Name string `json:"name"`
+		// Times of each coordinate as written by most GPX converters
+		CoordTimes json.RawMessage `json:"coordTimes"`
+	} `json:"properties"`
+}
+
+// ParseGeoJSON reads the LineStrings and MultiLineStrings of a geometry,
+// feature or feature collection. Times are read from the coordTimes property
+// when present.
+func ParseGeoJSON(r io.Reader) (*Route, error) {
+	var g geoJSON
+	if err := json.NewDecoder(r).Decode(&g); err != nil {
+		return nil, err
+	}
+
+	route := &Route{}
+	if err := route.addGeoJSON(&g, nil); err != nil {
+		return nil, err
+	}
+	return route.validate()
+}
+
+func (r *Route) addGeoJSON(g *geoJSON, coordTimes json.RawMessage) error {
+	switch g.Type {
+	case "FeatureCollection":
+		for i := range g.Features {
+			if err := r.addGeoJSON(&g.Features[i], nil); err != nil {
+				return err
+			}
+		}
+	case "Feature":
+		if r.Name == "" {
+			r.Name = g.Properties.Name
+		}
+		if g.Geometry != nil {
+			return r.addGeoJSON(g.Geometry, g.Properties.CoordTimes)
+		}
+	case "GeometryCollection":
+		for i := range g.Geometries {
+			if err := r.addGeoJSON(&g.Geometries[i], nil); err != nil {
+				return err
+			}
+		}
+	case "LineString":
+		var coords [][]float64
+		if err := json.Unmarshal(g.Coordinates, &coords); err != nil {
+			return err
+		}
+		var times []string
+		if len(coordTimes) > 0 {
+			if err := json.Unmarshal(coordTimes, &times); err != nil {
+				return err
+			}
+		}
+		return r.addLine(coords, times)
+	case "MultiLineString":
+		var lines [][][]float64
+		if err := json.Unmarshal(g.Coordinates, &lines); err != nil {
+			return err
+		}
+		var times [][]string
+		if len(coordTimes) > 0 {
+			if err := json.Unmarshal(coordTimes, &times); err != nil {
+				return err
+			}
+		}
+		for i, coords := range lines {
+			var lineTimes []string
+			if i < len(times) {
+				lineTimes = times[i]
+			}
+			if err := r.addLine(coords, lineTimes); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (r *Route) addLine(coords [][]float64, times []string) error {
+	for i, c := range coords {
+		if len(c) < 2 {
+			return fmt.Errorf("Invalid GeoJSON position %v", c)
+		}
+		w := Waypoint{Longitude: c[0], Latitude: c[1]}
+		if len(c) > 2 {
+			w.Elevation = c[2]
+			w.HasElevation = true
+		}
+		if i < len(times) {
+			t, err := parseTime(times[i])
+			if err != nil {
+				return err
+			}
+			w.Time = t
+		}
+		r.Waypoints = append(r.Waypoints, w)
+	}
+	return nil
+}
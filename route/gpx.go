@@ -0,0 +1,78 @@
+package route
+
+import (
+	"encoding/xml"
+	"io"
+)
+
+type gpxPoint struct {
+	Lat  float64  `xml:"lat,attr"`
+	Lon  float64  `xml:"lon,attr"`
+	Ele  *float64 `xml:"ele"`
+	Time string   `xml:"time"`
+}
+
+type gpxFile struct {
+	Name   string `xml:"metadata>name"`
+	Tracks []struct {
+		Name     string `xml:"name"`
+		Segments []struct {
+			Points []gpxPoint `xml:"trkpt"`
+		} `xml:"trkseg"`
+	} `xml:"trk"`
+	Routes []struct {
+		Name   string     `xml:"name"`
+		Points []gpxPoint `xml:"rtept"`
+	} `xml:"rte"`
+}
+
+// ParseGPX reads the track points of every track segment, or the route points
+// when the file has no track
+func ParseGPX(r io.Reader) (*Route, error) {
+	var f gpxFile
+	if err := xml.NewDecoder(r).Decode(&f); err != nil {
+		return nil, err
+	}
+
+	route := &Route{Name: f.Name}
+	add := func(points []gpxPoint) error {
+		for _, p := range points {
+			w := Waypoint{Latitude: p.Lat, Longitude: p.Lon}
+			if p.Ele != nil {
+				w.Elevation = *p.Ele
+				w.HasElevation = true
+			}
+			t, err := parseTime(p.Time)
+			if err != nil {
+				return err
+			}
+			w.Time = t
+			route.Waypoints = append(route.Waypoints, w)
+		}
+		return nil
+	}
+
+	for _, trk := range f.Tracks {
+		if route.Name == "" {
+			route.Name = trk.Name
+		}
+		for _, seg := range trk.Segments {
+			if err := add(seg.Points); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if len(route.Waypoints) == 0 {
+		for _, rte := range f.Routes {
+			if route.Name == "" {
+				route.Name = rte.Name
+			}
+			if err := add(rte.Points); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return route.validate()
+}
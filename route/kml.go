@@ -0,0 +1,114 @@
+package route
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ParseKML reads the coordinates of every LineString and the timed points of
+// every gx:Track
+func ParseKML(r io.Reader) (*Route, error) {
+	route := &Route{}
+	d := xml.NewDecoder(r)
+
+	var (
+		stack  []string
+		text   strings.Builder
+		whens  []string
+		coords []string
+	)
+
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			stack = append(stack, t.Name.Local)
+			text.Reset()
+			if t.Name.Local == "Track" {
+				whens, coords = nil, nil
+			}
+		case xml.CharData:
+			text.Write(t)
+		case xml.EndElement:
+			parent := ""
+			if len(stack) > 1 {
+				parent = stack[len(stack)-2]
+			}
+			stack = stack[:len(stack)-1]
+
+			switch t.Name.Local {
+			case "name":
+				if route.Name == "" {
+					route.Name = strings.TrimSpace(text.String())
+				}
+			case "coordinates":
+				if parent != "LineString" {
+					break
+				}
+				for _, tuple := range strings.Fields(text.String()) {
+					w, err := parseKMLCoord(strings.Split(tuple, ","))
+					if err != nil {
+						return nil, err
+					}
+					route.Waypoints = append(route.Waypoints, w)
+				}
+			case "when":
+				if parent == "Track" {
+					whens = append(whens, text.String())
+				}
+			case "coord":
+				if parent == "Track" {
+					coords = append(coords, text.String())
+				}
+			case "Track":
+				for i, coord := range coords {
+					w, err := parseKMLCoord(strings.Fields(coord))
+					if err != nil {
+						return nil, err
+					}
+					if i < len(whens) {
+						if w.Time, err = parseTime(whens[i]); err != nil {
+							return nil, err
+						}
+					}
+					route.Waypoints = append(route.Waypoints, w)
+				}
+			}
+		}
+	}
+
+	return route.validate()
+}
+
+// parseKMLCoord parses a longitude, latitude and optional altitude tuple
+func parseKMLCoord(values []string) (Waypoint, error) {
+	if len(values) < 2 {
+		return Waypoint{}, fmt.Errorf("Invalid KML coordinate %q", strings.Join(values, ","))
+	}
+
+	var w Waypoint
+	var err error
+	if w.Longitude, err = strconv.ParseFloat(values[0], 64); err != nil {
+		return Waypoint{}, err
+	}
+	if w.Latitude, err = strconv.ParseFloat(values[1], 64); err != nil {
+		return Waypoint{}, err
+	}
+	if len(values) > 2 {
+		if w.Elevation, err = strconv.ParseFloat(values[2], 64); err != nil {
+			return Waypoint{}, err
+		}
+		w.HasElevation = true
+	}
+	return w, nil
+}
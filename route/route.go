@@ -0,0 +1,119 @@
+package route
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/globalpokecache/pogobuf-go/helpers"
+)
+
+var (
+	ErrNoWaypoints   = errors.New("Route has no waypoints")
+	ErrUnknownFormat = errors.New("Unknown route format")
+)
+
+// Waypoint is a point of a route. Time is zero and HasElevation false when the
+// file did not record them.
+type Waypoint struct {
+	Latitude     float64
+	Longitude    float64
+	Elevation    float64
+	HasElevation bool
+	Time         time.Time
+}
+
+// Route is the sequence of waypoints of every track or line of a file, in the
+// order they appear
+type Route struct {
+	Name      string
+	Waypoints []Waypoint
+}
+
+// Load parses a .gpx, .kml, .geojson or .json file
+func Load(path string) (*Route, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var r *Route
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".gpx":
+		r, err = ParseGPX(f)
+	case ".kml":
+		r, err = ParseKML(f)
+	case ".geojson", ".json":
+		r, err = ParseGeoJSON(f)
+	default:
+		return nil, ErrUnknownFormat
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	return r, nil
+}
+
+func (r *Route) validate() (*Route, error) {
+	if len(r.Waypoints) == 0 {
+		return nil, ErrNoWaypoints
+	}
+	for _, w := range r.Waypoints {
+		if w.Latitude < -90 || w.Latitude > 90 || w.Longitude < -180 || w.Longitude > 180 {
+			return nil, fmt.Errorf("Invalid waypoint %v,%v", w.Latitude, w.Longitude)
+		}
+	}
+	return r, nil
+}
+
+// Path returns the waypoint positions
+func (r *Route) Path() []helpers.LatLng {
+	path := make([]helpers.LatLng, len(r.Waypoints))
+	for i, w := range r.Waypoints {
+		path[i] = helpers.LatLng{Lat: w.Latitude, Lng: w.Longitude}
+	}
+	return path
+}
+
+// Timed reports whether every waypoint has a time and they never go back, so
+// the route can be replayed
+func (r *Route) Timed() bool {
+	if len(r.Waypoints) == 0 {
+		return false
+	}
+	for i, w := range r.Waypoints {
+		if w.Time.IsZero() {
+			return false
+		}
+		if i > 0 && w.Time.Before(r.Waypoints[i-1].Time) {
+			return false
+		}
+	}
+	return true
+}
+
+// Length returns the route length in meters
+func (r *Route) Length() float64 {
+	return helpers.RouteDistance(r.Path())
+}
+
+// Duration returns the recorded time between the first and last waypoints, 0
+// when the route is not timed
+func (r *Route) Duration() time.Duration {
+	if !r.Timed() {
+		return 0
+	}
+	return r.Waypoints[len(r.Waypoints)-1].Time.Sub(r.Waypoints[0].Time)
+}
+
+func parseTime(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
@@ -0,0 +1,87 @@
+package route
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseGPX(t *testing.T) {
+	r, err := ParseGPX(strings.NewReader(`<?xml version="1.0"?>
+<gpx version="1.1" xmlns="http://www.topografix.com/GPX/1/1">
+	<trk><name>Park loop</name><trkseg>
+		<trkpt lat="-23.5505" lon="-46.6333"><ele>760</ele><time>2017-01-01T10:00:00Z</time></trkpt>
+		<trkpt lat="-23.5510" lon="-46.6340"><ele>762.5</ele><time>2017-01-01T10:01:00Z</time></trkpt>
+	</trkseg></trk>
+</gpx>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Name != "Park loop" || len(r.Waypoints) != 2 {
+		t.Fatalf("Unexpected route %+v", r)
+	}
+	w := r.Waypoints[1]
+	if w.Latitude != -23.5510 || w.Longitude != -46.6340 || !w.HasElevation || w.Elevation != 762.5 {
+		t.Fatalf("Unexpected waypoint %+v", w)
+	}
+	if !r.Timed() || r.Duration() != time.Minute {
+		t.Fatalf("Expected a timed route of one minute, got %s", r.Duration())
+	}
+}
+
+func TestParseKML(t *testing.T) {
+	r, err := ParseKML(strings.NewReader(`<?xml version="1.0"?>
+<kml xmlns="http://www.opengis.net/kml/2.2" xmlns:gx="http://www.google.com/kml/ext/2.2">
+	<Document><name>Walk</name>
+		<Placemark><LineString><coordinates>
+			-46.6333,-23.5505,760 -46.6340,-23.5510
+		</coordinates></LineString></Placemark>
+		<Placemark><gx:Track>
+			<when>2017-01-01T10:00:00Z</when>
+			<gx:coord>-46.6350 -23.5520 770</gx:coord>
+		</gx:Track></Placemark>
+	</Document>
+</kml>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Name != "Walk" || len(r.Waypoints) != 3 {
+		t.Fatalf("Unexpected route %+v", r)
+	}
+	if w := r.Waypoints[0]; w.Latitude != -23.5505 || w.Longitude != -46.6333 || w.Elevation != 760 {
+		t.Fatalf("Unexpected waypoint %+v", w)
+	}
+	if r.Waypoints[1].HasElevation {
+		t.Fatal("Waypoint without altitude has an elevation")
+	}
+	if r.Waypoints[2].Time.IsZero() || r.Timed() {
+		t.Fatal("Only the track point must be timed")
+	}
+}
+
+func TestParseGeoJSON(t *testing.T) {
+	r, err := ParseGeoJSON(strings.NewReader(`{
+		"type": "FeatureCollection",
+		"features": [{
+			"type": "Feature",
+			"properties": {
+				"name": "Commute",
+				"coordTimes": ["2017-01-01T10:00:00Z", "2017-01-01T10:00:30Z"]
+			},
+			"geometry": {"type": "LineString", "coordinates": [[-46.6333, -23.5505], [-46.6340, -23.5510, 5]]}
+		}]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Name != "Commute" || len(r.Waypoints) != 2 || !r.Timed() {
+		t.Fatalf("Unexpected route %+v", r)
+	}
+	if r.Duration() != 30*time.Second {
+		t.Fatalf("Expected 30s, got %s", r.Duration())
+	}
+
+	if _, err := ParseGeoJSON(strings.NewReader(`{"type": "Point", "coordinates": [0, 0]}`)); err != ErrNoWaypoints {
+		t.Fatalf("Expected ErrNoWaypoints, got %v", err)
+	}
+}
@@ -0,0 +1,367 @@
+package spawns
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/globalpokecache/POGOProtos-go"
+	"github.com/globalpokecache/pogobuf-go/helpers"
+	"github.com/golang/geo/s2"
+)
+
+// Spawn points spawn once an hour and stay up for one of these durations
+var durations = []time.Duration{15 * time.Minute, 30 * time.Minute, 60 * time.Minute}
+
+const (
+	maxSightings = 50
+	// Despawn times that differ less than this are taken as the same
+	despawnTolerance = 10 * time.Second
+)
+
+// Sighting is a pokemon seen at a spawn point
+type Sighting struct {
+	EncounterID uint64
+	PokemonID   protos.PokemonId
+	Seen        time.Time
+	// Despawn is zero when the server did not send the time till hidden
+	Despawn time.Time
+}
+
+// Point is a spawn point and what was learned about it
+type Point struct {
+	ID        string
+	Latitude  float64
+	Longitude float64
+	Sightings []Sighting
+
+	// Learned is set once a despawn time was seen, DespawnOffset is then the
+	// despawn time past the full hour and Duration the shortest spawn window
+	// matching every sighting
+	Learned       bool
+	DespawnOffset time.Duration
+	Duration      time.Duration
+	Confidence    float64
+}
+
+// Prediction is the next active window of a spawn point
+type Prediction struct {
+	SpawnPointID string
+	Latitude     float64
+	Longitude    float64
+	Start        time.Time
+	End          time.Time
+	Active       bool
+	Confidence   float64
+}
+
+// Store persists the learned spawn points
+type Store interface {
+	Load() ([]Point, error)
+	Save(points []Point) error
+}
+
+// Tracker learns spawn windows from the pokemon seen on GET_MAP_OBJECTS
+// responses. It is safe for concurrent use.
+type Tracker struct {
+	mu     sync.RWMutex
+	store  Store
+	points map[string]*Point
+	dirty  bool
+}
+
+// NewTracker creates a tracker with the points saved in the store, a nil store
+// keeps them in memory only
+func NewTracker(store Store) (*Tracker, error) {
+	if store == nil {
+		store = NewMemoryStore()
+	}
+
+	t := &Tracker{
+		store:  store,
+		points: map[string]*Point{},
+	}
+
+	points, err := store.Load()
+	if err != nil {
+		return nil, err
+	}
+	for i := range points {
+		p := points[i]
+		p.Sightings = append([]Sighting{}, p.Sightings...)
+		t.points[p.ID] = &p
+	}
+
+	return t, nil
+}
+
+// PointID returns the id used for spawn points reported without one, it
+// matches the SpawnPointId of the pokemon spawning there
+func PointID(lat, lng float64) string {
+	return s2.CellIDFromLatLng(s2.LatLngFromDegrees(lat, lng)).Parent(20).ToToken()
+}
+
+func msToTime(ms int64) time.Time {
+	return time.Unix(0, ms*int64(time.Millisecond))
+}
+
+func (t *Tracker) point(id string, lat, lng float64) *Point {
+	p, ok := t.points[id]
+	if !ok {
+		p = &Point{ID: id, Latitude: lat, Longitude: lng}
+		t.points[id] = p
+		t.dirty = true
+	}
+	return p
+}
+
+// Observe records the spawn points and wild pokemon of a response
+func (t *Tracker) Observe(resp *protos.GetMapObjectsResponse) {
+	if resp == nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, cell := range resp.MapCells {
+		for _, sp := range cell.SpawnPoints {
+			t.point(PointID(sp.Latitude, sp.Longitude), sp.Latitude, sp.Longitude)
+		}
+
+		for _, w := range cell.WildPokemons {
+			if w.SpawnPointId == "" {
+				continue
+			}
+
+			s := Sighting{EncounterID: w.EncounterId}
+			if w.PokemonData != nil {
+				s.PokemonID = w.PokemonData.PokemonId
+			}
+			switch {
+			case w.LastModifiedTimestampMs > 0:
+				s.Seen = msToTime(w.LastModifiedTimestampMs)
+			case cell.CurrentTimestampMs > 0:
+				s.Seen = msToTime(cell.CurrentTimestampMs)
+			default:
+				s.Seen = time.Now()
+			}
+			// Out of range values were sent by some versions when unknown
+			if w.TimeTillHiddenMs > 0 && w.TimeTillHiddenMs <= int32(time.Hour/time.Millisecond) {
+				s.Despawn = s.Seen.Add(time.Duration(w.TimeTillHiddenMs) * time.Millisecond)
+			}
+
+			p := t.point(w.SpawnPointId, w.Latitude, w.Longitude)
+			p.addSighting(s)
+			p.learn()
+			t.dirty = true
+		}
+	}
+}
+
+func (p *Point) addSighting(s Sighting) {
+	for i, known := range p.Sightings {
+		if known.EncounterID != s.EncounterID {
+			continue
+		}
+		if s.Seen.Before(known.Seen) {
+			p.Sightings[i].Seen = s.Seen
+		}
+		if !s.Despawn.IsZero() {
+			p.Sightings[i].Despawn = s.Despawn
+		}
+		return
+	}
+
+	p.Sightings = append(p.Sightings, s)
+	if len(p.Sightings) > maxSightings {
+		p.Sightings = p.Sightings[len(p.Sightings)-maxSightings:]
+	}
+}
+
+func offsetDiff(a, b time.Duration) time.Duration {
+	d := a - b
+	if d < 0 {
+		d = -d
+	}
+	if d > time.Hour/2 {
+		d = time.Hour - d
+	}
+	return d
+}
+
+// learn infers the spawn window from the sightings with a despawn time: the
+// despawn offset most of them agree on, and the shortest standard duration
+// covering the longest time a pokemon was seen before despawning
+func (p *Point) learn() {
+	var offsets []time.Duration
+	for _, s := range p.Sightings {
+		if !s.Despawn.IsZero() {
+			offsets = append(offsets, s.Despawn.Sub(s.Despawn.Truncate(time.Hour)))
+		}
+	}
+	if len(offsets) == 0 {
+		return
+	}
+
+	best, bestVotes := offsets[0], 0
+	for _, candidate := range offsets {
+		votes := 0
+		for _, o := range offsets {
+			if offsetDiff(candidate, o) <= despawnTolerance {
+				votes++
+			}
+		}
+		if votes > bestVotes {
+			best, bestVotes = candidate, votes
+		}
+	}
+
+	var seenBefore time.Duration
+	for _, s := range p.Sightings {
+		if s.Despawn.IsZero() {
+			continue
+		}
+		offset := s.Despawn.Sub(s.Despawn.Truncate(time.Hour))
+		if offsetDiff(offset, best) > despawnTolerance {
+			continue
+		}
+		if d := s.Despawn.Sub(s.Seen); d > seenBefore {
+			seenBefore = d
+		}
+	}
+
+	duration := durations[len(durations)-1]
+	for _, d := range durations {
+		if seenBefore <= d {
+			duration = d
+			break
+		}
+	}
+
+	p.Learned = true
+	p.DespawnOffset = best
+	p.Duration = duration
+	p.Confidence = float64(bestVotes) / float64(len(offsets))
+}
+
+// next returns the active window ending after now
+func (p *Point) next(now time.Time) (time.Time, time.Time) {
+	end := now.Truncate(time.Hour).Add(p.DespawnOffset)
+	for !end.After(now) {
+		end = end.Add(time.Hour)
+	}
+	return end.Add(-p.Duration), end
+}
+
+// Predict returns the current or next active window of a learned spawn point
+func (t *Tracker) Predict(id string, now time.Time) (Prediction, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	p, ok := t.points[id]
+	if !ok || !p.Learned {
+		return Prediction{}, false
+	}
+	return p.predict(now), true
+}
+
+func (p *Point) predict(now time.Time) Prediction {
+	start, end := p.next(now)
+	return Prediction{
+		SpawnPointID: p.ID,
+		Latitude:     p.Latitude,
+		Longitude:    p.Longitude,
+		Start:        start,
+		End:          end,
+		Active:       !start.After(now),
+		Confidence:   p.Confidence,
+	}
+}
+
+// Upcoming returns the learned spawn points within radius meters of lat, lng
+// that are active now or become active before now+within, soonest first
+func (t *Tracker) Upcoming(lat, lng, radius float64, within time.Duration, now time.Time) []Prediction {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	center := helpers.LatLng{Lat: lat, Lng: lng}
+	limit := now.Add(within)
+
+	var predictions []Prediction
+	for _, p := range t.points {
+		if !p.Learned {
+			continue
+		}
+		if helpers.Distance(center, helpers.LatLng{Lat: p.Latitude, Lng: p.Longitude}) > radius {
+			continue
+		}
+		prediction := p.predict(now)
+		if prediction.Start.After(limit) {
+			continue
+		}
+		predictions = append(predictions, prediction)
+	}
+
+	sort.Slice(predictions, func(i, j int) bool {
+		if !predictions[i].Start.Equal(predictions[j].Start) {
+			return predictions[i].Start.Before(predictions[j].Start)
+		}
+		return predictions[i].SpawnPointID < predictions[j].SpawnPointID
+	})
+
+	return predictions
+}
+
+// Point returns a copy of a known spawn point
+func (t *Tracker) Point(id string) (Point, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	p, ok := t.points[id]
+	if !ok {
+		return Point{}, false
+	}
+	return p.copy(), true
+}
+
+// Points returns a copy of every known spawn point sorted by id
+func (t *Tracker) Points() []Point {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	return t.snapshot()
+}
+
+func (t *Tracker) snapshot() []Point {
+	points := make([]Point, 0, len(t.points))
+	for _, p := range t.points {
+		points = append(points, p.copy())
+	}
+	sort.Slice(points, func(i, j int) bool {
+		return points[i].ID < points[j].ID
+	})
+	return points
+}
+
+func (p *Point) copy() Point {
+	c := *p
+	c.Sightings = append([]Sighting{}, p.Sightings...)
+	return c
+}
+
+// Save writes the points to the store when something changed since the last
+// save
+func (t *Tracker) Save() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if !t.dirty {
+		return nil
+	}
+	if err := t.store.Save(t.snapshot()); err != nil {
+		return err
+	}
+	t.dirty = false
+	return nil
+}
@@ -0,0 +1,82 @@
+package spawns
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/globalpokecache/POGOProtos-go"
+)
+
+func wild(id uint64, seen time.Time, tth time.Duration) *protos.GetMapObjectsResponse {
+	return &protos.GetMapObjectsResponse{MapCells: []*protos.MapCell{{
+		WildPokemons: []*protos.WildPokemon{{
+			EncounterId:             id,
+			SpawnPointId:            "sp",
+			Latitude:                10,
+			Longitude:               10,
+			LastModifiedTimestampMs: seen.UnixNano() / int64(time.Millisecond),
+			TimeTillHiddenMs:        int32(tth / time.Millisecond),
+		}},
+	}}}
+}
+
+func TestTrackerLearnsWindow(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spawns")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	store := NewFileStore(filepath.Join(dir, "spawns.json"))
+
+	tracker, err := NewTracker(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hour := time.Date(2017, 8, 1, 10, 0, 0, 0, time.UTC)
+	// Despawns at 10:42:30, first seen 20 minutes before
+	tracker.Observe(wild(1, hour.Add(22*time.Minute+30*time.Second), 20*time.Minute))
+	tracker.Observe(wild(1, hour.Add(30*time.Minute), 12*time.Minute+30*time.Second))
+	// Next hour, seen late
+	tracker.Observe(wild(2, hour.Add(time.Hour+40*time.Minute), 2*time.Minute+30*time.Second))
+
+	p, ok := tracker.Point("sp")
+	if !ok || !p.Learned {
+		t.Fatal("Expected the spawn point to be learned")
+	}
+	if p.DespawnOffset != 42*time.Minute+30*time.Second {
+		t.Fatalf("Unexpected despawn offset %s", p.DespawnOffset)
+	}
+	if p.Duration != 30*time.Minute {
+		t.Fatalf("Unexpected duration %s", p.Duration)
+	}
+	if len(p.Sightings) != 2 {
+		t.Fatalf("Expected 2 sightings, got %d", len(p.Sightings))
+	}
+
+	now := hour.Add(3*time.Hour + 5*time.Minute)
+	if up := tracker.Upcoming(10, 10, 100, 5*time.Minute, now); len(up) != 0 {
+		t.Fatal("Spawn point is not due yet")
+	}
+	up := tracker.Upcoming(10, 10, 100, 10*time.Minute, now)
+	if len(up) != 1 || !up[0].Start.Equal(hour.Add(3*time.Hour+12*time.Minute+30*time.Second)) || up[0].Active {
+		t.Fatalf("Unexpected prediction %v", up)
+	}
+	if up := tracker.Upcoming(20, 20, 100, time.Hour, now); len(up) != 0 {
+		t.Fatal("Spawn point is out of range")
+	}
+
+	if err := tracker.Save(); err != nil {
+		t.Fatal(err)
+	}
+	reloaded, err := NewTracker(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pred, ok := reloaded.Predict("sp", now); !ok || !pred.End.Equal(hour.Add(3*time.Hour+42*time.Minute+30*time.Second)) {
+		t.Fatalf("Unexpected prediction after reload %v", pred)
+	}
+}
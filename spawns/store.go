@@ -0,0 +1,82 @@
+package spawns
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// MemoryStore keeps the points in memory, it is the default store
+type MemoryStore struct {
+	sync.Mutex
+	points []Point
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{}
+}
+
+func (s *MemoryStore) Load() ([]Point, error) {
+	s.Lock()
+	defer s.Unlock()
+	return append([]Point{}, s.points...), nil
+}
+
+func (s *MemoryStore) Save(points []Point) error {
+	s.Lock()
+	s.points = append([]Point{}, points...)
+	s.Unlock()
+	return nil
+}
+
+// FileStore keeps the points in a JSON file
+type FileStore struct {
+	path string
+}
+
+func NewFileStore(path string) *FileStore {
+	return &FileStore{path}
+}
+
+// Load returns no points when the file does not exist yet
+func (s *FileStore) Load() ([]Point, error) {
+	data, err := ioutil.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var points []Point
+	if err := json.Unmarshal(data, &points); err != nil {
+		return nil, err
+	}
+	return points, nil
+}
+
+// Save replaces the file atomically so a crash never leaves it half written
+func (s *FileStore) Save(points []Point) error {
+	data, err := json.MarshalIndent(points, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(s.path), filepath.Base(s.path)+".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	return os.Rename(tmp.Name(), s.path)
+}
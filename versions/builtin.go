@@ -0,0 +1,60 @@
+package versions
+
+var (
+	// Twofish key of the signature encryption since 0.45
+	twofishKey = HexBytes{
+		0x4F, 0xEB, 0x1C, 0xA5, 0xF6, 0x1A, 0x67, 0xCE,
+		0x43, 0xF3, 0xF0, 0x0C, 0xB1, 0x23, 0x88, 0x35,
+		0xE9, 0x8B, 0xE8, 0x39, 0xD8, 0x89, 0x8F, 0x5A,
+		0x3B, 0x51, 0x2E, 0xA9, 0x47, 0x38, 0xC4, 0x14,
+	}
+
+	// Settings hash sent by every version so far
+	settingsHash = "f43e9e403f233d4541feda9816e9d6085bccb087"
+
+	// The unk25 values were taken from the iOS app, no other value is known
+	// for the Android app of the same version so both platforms send them
+	builtin = []struct {
+		version   int
+		unk25     int64
+		buddyauth string
+	}{
+		{6100, 1296456256998993698, "api/v131_0/hash"},
+		{5902, -3226782243204485589, "api/v129_2/hash"},
+		{5901, -3226782243204485589, "api/v129_1/hash"},
+		{5704, -816976800928766045, "api/v127_4/hash"},
+		{5703, -816976800928766045, "api/v127_3/hash"},
+		{5702, -816976800928766045, "api/v127_2/hash"},
+		{5500, -9156899491064153954, "api/v125/hash"},
+		{5300, -8832040574896607694, "api/v123_1/hash"},
+		{5100, -76506539888958491, "api/v121_2/hash"},
+		{4500, -8408506833887075802, "api/hash"},
+	}
+)
+
+// The versions before 0.45 encrypted their signatures with the unknown6
+// scheme, which has no key, and hashed them with xxHash
+var unknown6Versions = []int{3300, 3500, 3700, 3900, 4100, 4300}
+
+func init() {
+	for _, version := range unknown6Versions {
+		Register(Profile{
+			Version:      version,
+			SettingsHash: settingsHash,
+			Platforms:    []string{PlatformIOS, PlatformAndroid},
+		})
+	}
+	for _, b := range builtin {
+		Register(Profile{
+			Version:      b.version,
+			Unknown25:    b.unk25,
+			SettingsHash: settingsHash,
+			Encryption: Encryption{
+				Key:           twofishKey,
+				IntegrityByte: 0x23,
+			},
+			HashEndpoints: map[string]string{"buddyauth": b.buddyauth},
+			Platforms:     []string{PlatformIOS, PlatformAndroid},
+		})
+	}
+}
@@ -0,0 +1,174 @@
+package versions
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"sync"
+
+	"github.com/globalpokecache/pogobuf-go/pcrypt"
+)
+
+// Default is the version used when none is set
+const Default = 5500
+
+const (
+	PlatformIOS     = "ios"
+	PlatformAndroid = "android"
+)
+
+var ErrUnsupportedVersion = errors.New("Unsupported API version")
+
+// HexBytes is a byte slice written as a hex string in JSON
+type HexBytes []byte
+
+func (b HexBytes) MarshalJSON() ([]byte, error) {
+	return json.Marshal(hex.EncodeToString(b))
+}
+
+func (b *HexBytes) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	decoded, err := hex.DecodeString(s)
+	if err != nil {
+		return err
+	}
+	*b = decoded
+	return nil
+}
+
+// Encryption holds the parameters of the signature encryption
+type Encryption struct {
+	Key           HexBytes `json:"key"`
+	IntegrityByte byte     `json:"integrity_byte"`
+}
+
+// Profile bundles what changes from one game version to another
+type Profile struct {
+	// Version is the API version, 5500 for 0.55.0
+	Version int `json:"version"`
+	// Unknown25 is sent in the signature, one value for every platform
+	Unknown25 int64 `json:"unk25"`
+	// SettingsHash is sent on the DOWNLOAD_SETTINGS of the common requests
+	SettingsHash string `json:"settings_hash"`
+	// Encryption is left empty before 0.45, pcrypt does not implement the
+	// scheme of these versions
+	Encryption Encryption `json:"encryption"`
+	// HashEndpoints maps a hash provider to the endpoint serving this
+	// version, the providers refuse the versions missing from it
+	HashEndpoints map[string]string `json:"hash_endpoints,omitempty"`
+	Platforms     []string          `json:"platforms"`
+}
+
+// Name returns the game version, 0.55.0 for 5500
+func (p Profile) Name() string {
+	return fmt.Sprintf("0.%d.%d", p.Version/100, p.Version%100)
+}
+
+// Supports reports whether the version runs on the platform
+func (p Profile) Supports(platform string) bool {
+	for _, s := range p.Platforms {
+		if s == platform {
+			return true
+		}
+	}
+	return false
+}
+
+// clone copies the key, endpoints and platforms so that the registry and its
+// callers never share them
+func (p Profile) clone() Profile {
+	p.Encryption.Key = append(HexBytes{}, p.Encryption.Key...)
+	p.Platforms = append([]string{}, p.Platforms...)
+	if p.HashEndpoints != nil {
+		endpoints := make(map[string]string, len(p.HashEndpoints))
+		for provider, endpoint := range p.HashEndpoints {
+			endpoints[provider] = endpoint
+		}
+		p.HashEndpoints = endpoints
+	}
+	return p
+}
+
+func (p Profile) validate() error {
+	if p.Version <= 0 {
+		return fmt.Errorf("Invalid version %d", p.Version)
+	}
+	if p.Version >= pcrypt.TwofishVersion && len(p.Encryption.Key) != 32 {
+		return fmt.Errorf("%s: encryption key must be 32 bytes", p.Name())
+	}
+	if len(p.Platforms) == 0 {
+		return fmt.Errorf("%s: no platform", p.Name())
+	}
+	return nil
+}
+
+var (
+	registryMutex sync.RWMutex
+	registry      = map[int]Profile{}
+)
+
+// Register adds a profile, replacing the one of the same version
+func Register(p Profile) error {
+	if err := p.validate(); err != nil {
+		return err
+	}
+
+	registryMutex.Lock()
+	registry[p.Version] = p.clone()
+	registryMutex.Unlock()
+	return nil
+}
+
+// Get returns a copy of the profile of a version
+func Get(version int) (Profile, error) {
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+
+	p, ok := registry[version]
+	if !ok {
+		return Profile{}, ErrUnsupportedVersion
+	}
+	return p.clone(), nil
+}
+
+// List returns the registered versions, oldest first
+func List() []int {
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+
+	list := make([]int, 0, len(registry))
+	for v := range registry {
+		list = append(list, v)
+	}
+	sort.Ints(list)
+	return list
+}
+
+// Load registers the profiles of a JSON file holding a list of them
+func Load(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var profiles []Profile
+	if err := json.Unmarshal(data, &profiles); err != nil {
+		return fmt.Errorf("%s: %s", path, err)
+	}
+
+	for _, p := range profiles {
+		if err := p.validate(); err != nil {
+			return fmt.Errorf("%s: %s", path, err)
+		}
+	}
+	for _, p := range profiles {
+		Register(p)
+	}
+	return nil
+}
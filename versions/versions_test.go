@@ -0,0 +1,69 @@
+package versions
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	if _, err := Get(9900); err != ErrUnsupportedVersion {
+		t.Fatalf("Expected ErrUnsupportedVersion, got %v", err)
+	}
+
+	f, err := ioutil.TempFile("", "versions")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString(`[{
+		"version": 9900,
+		"unk25": 42,
+		"settings_hash": "abc",
+		"encryption": {"key": "4feb1ca5f61a67ce43f3f00cb1238835e98be839d8898f5a3b512ea94738c414", "integrity_byte": 35},
+		"hash_endpoints": {"buddyauth": "api/v999/hash"},
+		"platforms": ["android"]
+	}]`)
+	f.Close()
+
+	if err := Load(f.Name()); err != nil {
+		t.Fatal(err)
+	}
+	p, err := Get(9900)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Name() != "0.99.0" || p.Unknown25 != 42 || p.Supports(PlatformIOS) || !p.Supports(PlatformAndroid) {
+		t.Fatalf("Unexpected profile %+v", p)
+	}
+	if string(p.Encryption.Key) != string(twofishKey) {
+		t.Fatal("Encryption key not decoded")
+	}
+}
+
+func TestGetCopy(t *testing.T) {
+	p, err := Get(Default)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Encryption.Key[0]++
+	p.HashEndpoints["buddyauth"] = "changed"
+
+	p, _ = Get(Default)
+	if string(p.Encryption.Key) != string(twofishKey) || p.HashEndpoints["buddyauth"] != "api/v125/hash" {
+		t.Fatal("Changes to a returned profile reached the registry")
+	}
+}
+
+func TestUnknown6Profiles(t *testing.T) {
+	p, err := Get(4300)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.Encryption.Key) != 0 || p.Unknown25 != 0 {
+		t.Fatalf("Unexpected 0.43 profile %+v", p)
+	}
+	if err := Register(Profile{Version: 4500, Platforms: []string{PlatformIOS}}); err == nil {
+		t.Fatal("Expected a 0.45 profile without a key to be rejected")
+	}
+}